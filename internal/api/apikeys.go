@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kubernetes-api/internal/auth"
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/validation"
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// listAPIKeysHandler handles GET /api/v1/api-keys, listing the caller's keys
+func (s *server) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	keys, err := s.apiKeys.List(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query API keys")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"api_keys": keys,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode API keys response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// createAPIKeyHandler handles POST /api/v1/api-keys. The key is only shown in this response.
+func (s *server) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	var req models.APIKeyRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		problem.Validation(w, r, validation.Errors{{Field: "expires_at", Rule: "future", Message: "must be in the future"}})
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to generate API key")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	apiKey := models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []models.Scope{}
+	}
+	if err := s.apiKeys.Create(r.Context(), &apiKey); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to create API key")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Return response
+	w.WriteHeader(http.StatusCreated)
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "API key created; store the key now, it will not be shown again",
+		Data: map[models.DataKey]interface{}{
+			"api_key": apiKey,
+			"key":     key,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode API key response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// revokeAPIKeyHandler handles DELETE /api/v1/api-keys/{id}
+func (s *server) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := s.apiKeys.Revoke(r.Context(), userID, keyID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusNotFound, "API key not found")
+		} else {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to revoke API key")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "API key revoked successfully",
+		Data:    map[models.DataKey]interface{}{},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode API key response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// checkAttributeSchemas validates each namespace of attrs against its schema,
+// if it has one. Namespaces without a schema accept any value.
+func (s *server) checkAttributeSchemas(ctx context.Context, attrs map[string]interface{}) (validation.Errors, error) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	schemas, err := s.attributeSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return schemaErrors(schemas, attrs), nil
+}
+
+// attributeSchemas loads and compiles the schemas of every namespace
+func (s *server) attributeSchemas(ctx context.Context) (map[string]*attributes.Schema, error) {
+	compiled := map[string]*attributes.Schema{}
+	if s.schemas == nil {
+		return compiled, nil
+	}
+
+	schemas, err := s.schemas.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		if compiled[schema.Namespace], err = attributes.CompileSchema(schema.Namespace, schema.Schema); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+// schemaErrors validates each namespace of attrs against its schema in schemas
+func schemaErrors(schemas map[string]*attributes.Schema, attrs map[string]interface{}) validation.Errors {
+	namespaces := make([]string, 0, len(attrs))
+	for namespace := range attrs {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var errs validation.Errors
+	for _, namespace := range namespaces {
+		schema, ok := schemas[namespace]
+		if !ok {
+			continue
+		}
+		for _, v := range schema.Validate(attrs[namespace]) {
+			field := "attributes." + namespace
+			if v.Path != "" {
+				field += "." + v.Path
+			}
+			errs = append(errs, validation.FieldError{Field: field, Rule: "schema", Message: v.Message})
+		}
+	}
+	return errs
+}
+
+// validateAttributes checks attrs against the namespace schemas. On failure
+// it writes the problem response and returns false.
+func (s *server) validateAttributes(w http.ResponseWriter, r *http.Request, attrs map[string]interface{}) bool {
+	errs, err := s.checkAttributeSchemas(r.Context(), attrs)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to load attribute schemas")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// listAttributeSchemasHandler handles GET /api/v1/attribute-schemas
+func (s *server) listAttributeSchemasHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	schemas, err := s.schemas.List(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query attribute schemas")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"schemas": schemas,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode attribute schemas response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// attributeSchemaHandler dispatches /api/v1/attribute-schemas/{namespace} by method
+func (s *server) attributeSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	namespace := mux.Vars(r)["namespace"]
+	if err := attributes.CheckName(namespace); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid namespace: "+err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getAttributeSchemaHandler(w, r, namespace)
+	case http.MethodPut:
+		s.putAttributeSchemaHandler(w, r, namespace)
+	case http.MethodDelete:
+		s.deleteAttributeSchemaHandler(w, r, namespace)
+	default:
+		problem.Error(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// getAttributeSchemaHandler handles GET /api/v1/attribute-schemas/{namespace}
+func (s *server) getAttributeSchemaHandler(w http.ResponseWriter, r *http.Request, namespace string) {
+	schema, err := s.schemas.Get(r.Context(), namespace)
+	if err != nil {
+		writeAttributeSchemaError(w, r, err, "Failed to query attribute schema")
+		return
+	}
+
+	writeAttributeSchema(w, r, "", schema)
+}
+
+// putAttributeSchemaHandler handles PUT /api/v1/attribute-schemas/{namespace}.
+// The body is the JSON Schema document. Items written earlier are not
+// revalidated.
+func (s *server) putAttributeSchemaHandler(w http.ResponseWriter, r *http.Request, namespace string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Request body must be a JSON Schema object")
+		return
+	}
+	if _, err := attributes.CompileSchema(namespace, body); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, "Invalid JSON Schema: "+err.Error())
+		return
+	}
+
+	schema := models.AttributeSchema{Namespace: namespace, Schema: body}
+	if err := s.schemas.Put(r.Context(), &schema); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to store attribute schema")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	logging.FromContext(r.Context()).Infof("Attribute schema %s updated", namespace)
+
+	writeAttributeSchema(w, r, "Attribute schema saved", schema)
+}
+
+// deleteAttributeSchemaHandler handles DELETE /api/v1/attribute-schemas/{namespace}
+func (s *server) deleteAttributeSchemaHandler(w http.ResponseWriter, r *http.Request, namespace string) {
+	if err := s.schemas.Delete(r.Context(), namespace); err != nil {
+		writeAttributeSchemaError(w, r, err, "Failed to delete attribute schema")
+		return
+	}
+	logging.FromContext(r.Context()).Infof("Attribute schema %s deleted", namespace)
+
+	// Return response
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Attribute schema deleted",
+		Data:    map[models.DataKey]interface{}{},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode attribute schema response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// writeAttributeSchema writes a single schema response
+func writeAttributeSchema(w http.ResponseWriter, r *http.Request, message string, schema models.AttributeSchema) {
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: message,
+		Data: map[models.DataKey]interface{}{
+			"schema": schema,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode attribute schema response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// writeAttributeSchemaError maps a repository error onto a problem response
+func writeAttributeSchemaError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, r, http.StatusNotFound, "Attribute schema not found")
+		return
+	}
+	logging.FromContext(r.Context()).WithError(err).Error(msg)
+	problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/validation"
+	"kubernetes-api/pkg/utils"
+)
+
+const (
+	// defaultMaxBatchItems is used when Dependencies.MaxBatchItems is unset
+	defaultMaxBatchItems = 100
+	// maxBatchBodyBytes caps the size of batch request bodies
+	maxBatchBodyBytes = 16 << 20
+)
+
+// batchResult is the outcome of one entry of a batch request
+type batchResult struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	Item   *models.Item      `json:"item,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// batchEntry is one entry of a batch request, to be written unless it failed
+// validation
+type batchEntry struct {
+	item   *models.Item
+	errors validation.Errors
+}
+
+// batchOp is one of the batch writes
+type batchOp struct {
+	// action names the write in log messages
+	action string
+	// status is reported for entries that were written
+	status int
+	// withItem includes the written item in the results
+	withItem bool
+	// all writes every item in one transaction, one writes a single item
+	all func(ctx context.Context, items []*models.Item) error
+	one func(ctx context.Context, item *models.Item) error
+}
+
+// batchCreateItemsHandler handles POST /api/v1/items:batchCreate
+func (s *server) batchCreateItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := batchCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.BatchCreateRequest
+	if !decodeRequestLimit(w, r, &req, maxBatchBodyBytes) || !s.checkBatch(w, r, &req.Mode, len(req.Items)) {
+		return
+	}
+	schemas, ok := s.batchSchemas(w, r)
+	if !ok {
+		return
+	}
+
+	entries := make([]batchEntry, len(req.Items))
+	for i, itemReq := range req.Items {
+		entries[i] = itemEntry(schemas, itemReq, models.Item{OwnerID: userID}, nil)
+	}
+
+	s.runBatch(w, r, req.Mode, entries, batchOp{
+		action:   "created",
+		status:   http.StatusCreated,
+		withItem: true,
+		all:      s.items.CreateBatch,
+		one:      s.items.Create,
+	})
+}
+
+// batchUpdateItemsHandler handles POST /api/v1/items:batchUpdate
+func (s *server) batchUpdateItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := batchCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.BatchUpdateRequest
+	if !decodeRequestLimit(w, r, &req, maxBatchBodyBytes) || !s.checkBatch(w, r, &req.Mode, len(req.Items)) {
+		return
+	}
+	schemas, ok := s.batchSchemas(w, r)
+	if !ok {
+		return
+	}
+
+	entries := make([]batchEntry, len(req.Items))
+	for i, e := range req.Items {
+		item := models.Item{ID: e.ID, OwnerID: userID, Version: e.Version}
+		entries[i] = itemEntry(schemas, e.ItemRequest, item, s.checkBatchTarget(e.ID, e.Version))
+	}
+
+	s.runBatch(w, r, req.Mode, entries, batchOp{
+		action:   "updated",
+		status:   http.StatusOK,
+		withItem: true,
+		all:      s.items.UpdateBatch,
+		one:      s.items.Update,
+	})
+}
+
+// batchDeleteItemsHandler handles POST /api/v1/items:batchDelete, moving the
+// items to the trash
+func (s *server) batchDeleteItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := batchCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.BatchDeleteRequest
+	if !decodeRequestLimit(w, r, &req, maxBatchBodyBytes) || !s.checkBatch(w, r, &req.Mode, len(req.Items)) {
+		return
+	}
+
+	entries := make([]batchEntry, len(req.Items))
+	for i, e := range req.Items {
+		entries[i] = batchEntry{
+			item:   &models.Item{ID: e.ID, OwnerID: userID, Version: e.Version},
+			errors: s.checkBatchTarget(e.ID, e.Version),
+		}
+	}
+
+	s.runBatch(w, r, req.Mode, entries, batchOp{
+		action: "deleted",
+		status: http.StatusOK,
+		all:    s.items.DeleteBatch,
+		one: func(ctx context.Context, item *models.Item) error {
+			return s.items.Delete(ctx, item.OwnerID, item.ID, item.Version)
+		},
+	})
+}
+
+// batchCaller reads the caller of a batch request. On failure it writes the
+// problem response and returns false.
+func batchCaller(w http.ResponseWriter, r *http.Request) (int, bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return 0, false
+	}
+	return userID, true
+}
+
+// checkBatch validates the mode and size of a batch, defaulting the mode to
+// atomic. On failure it writes the problem response and returns false.
+func (s *server) checkBatch(w http.ResponseWriter, r *http.Request, mode *models.BatchMode, size int) bool {
+	var errs validation.Errors
+	switch *mode {
+	case "":
+		*mode = models.BatchAtomic
+	case models.BatchAtomic, models.BatchBestEffort:
+	default:
+		errs = append(errs, validation.FieldError{Field: "mode", Rule: "oneof", Message: "must be atomic or best_effort"})
+	}
+
+	switch {
+	case size == 0:
+		errs = append(errs, validation.FieldError{Field: "items", Rule: "required", Message: "is required"})
+	case size > s.maxBatchItems:
+		errs = append(errs, validation.FieldError{Field: "items", Rule: "max", Message: fmt.Sprintf("must have at most %d entries", s.maxBatchItems)})
+	}
+
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// batchSchemas loads the attribute schemas once for a whole batch. On failure
+// it writes the problem response and returns false.
+func (s *server) batchSchemas(w http.ResponseWriter, r *http.Request) (map[string]*attributes.Schema, bool) {
+	schemas, err := s.attributeSchemas(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to load attribute schemas")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return nil, false
+	}
+	return schemas, true
+}
+
+// checkBatchTarget validates the item an update or delete entry refers to.
+// Entries must carry a version when If-Match is required.
+func (s *server) checkBatchTarget(id, version int) validation.Errors {
+	var errs validation.Errors
+	if id <= 0 {
+		errs = append(errs, validation.FieldError{Field: "id", Rule: "required", Message: "is required"})
+	}
+	if s.requireIfMatchHeader && version == 0 {
+		errs = append(errs, validation.FieldError{Field: "version", Rule: "required", Message: "is required when If-Match is enforced"})
+	}
+	return errs
+}
+
+// itemEntry validates the content of a created or updated item and copies it
+// into item
+func itemEntry(schemas map[string]*attributes.Schema, req models.ItemRequest, item models.Item, errs validation.Errors) batchEntry {
+	errs = append(errs, validation.Struct(&req)...)
+	if len(errs) == 0 {
+		errs = schemaErrors(schemas, req.Attributes)
+	}
+
+	item.Name, item.Description = req.Name, req.Description
+	item.Labels, item.Attributes = req.Labels, req.Attributes
+	return batchEntry{item: &item, errors: errs}
+}
+
+// runBatch writes the valid entries of a batch and responds with the outcome
+// of each. Atomic batches are refused as a whole if any entry is invalid or
+// cannot be written.
+func (s *server) runBatch(w http.ResponseWriter, r *http.Request, mode models.BatchMode, entries []batchEntry, op batchOp) {
+	results := make([]batchResult, len(entries))
+	failed := 0
+
+	if mode == models.BatchAtomic {
+		var errs validation.Errors
+		items := make([]*models.Item, len(entries))
+		for i, entry := range entries {
+			for _, fe := range entry.errors {
+				fe.Field = fmt.Sprintf("items[%d].%s", i, fe.Field)
+				errs = append(errs, fe)
+			}
+			items[i] = entry.item
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs)
+			return
+		}
+
+		if err := op.all(r.Context(), items); err != nil {
+			var batchErr *repository.BatchError
+			if !errors.As(err, &batchErr) {
+				logging.FromContext(r.Context()).WithError(err).Error("Failed to write item batch")
+				problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			status, detail := itemErrorStatus(batchErr.Err)
+			if status == http.StatusInternalServerError {
+				logging.FromContext(r.Context()).WithError(err).Error("Failed to write item batch")
+			}
+			problem.Error(w, r, status, fmt.Sprintf("items[%d]: %s; no items were %s", batchErr.Index, detail, op.action))
+			return
+		}
+
+		for i, item := range items {
+			results[i] = batchResult{Index: i, Status: op.status}
+			if op.withItem {
+				results[i].Item = item
+			}
+		}
+	} else {
+		for i, entry := range entries {
+			result := batchResult{Index: i}
+			if len(entry.errors) > 0 {
+				result.Status, result.Error, result.Errors = http.StatusUnprocessableEntity, "Request validation failed", entry.errors
+			} else if err := op.one(r.Context(), entry.item); err != nil {
+				result.Status, result.Error = itemErrorStatus(err)
+				if result.Status == http.StatusInternalServerError {
+					logging.FromContext(r.Context()).WithError(err).Errorf("Failed to write batch entry %d", i)
+				}
+			} else {
+				result.Status = op.status
+				if op.withItem {
+					result.Item = entry.item
+				}
+			}
+
+			if result.Status >= 400 {
+				failed++
+			}
+			results[i] = result
+		}
+	}
+	logging.FromContext(r.Context()).Infof("Batch of %d items %s, %d failed", len(entries), op.action, failed)
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"results":   results,
+			"succeeded": len(entries) - failed,
+			"failed":    failed,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode batch response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
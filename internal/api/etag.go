@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+)
+
+// itemETag returns the strong entity tag of an item, derived from its version
+func itemETag(item models.Item) string {
+	return `"` + strconv.Itoa(item.Version) + `"`
+}
+
+// setItemETag sets the ETag header for an item response
+func setItemETag(w http.ResponseWriter, item models.Item) {
+	w.Header().Set("ETag", itemETag(item))
+}
+
+// parseETags splits an If-Match or If-None-Match header into its entity tags
+func parseETags(r *http.Request, header string) []string {
+	var tags []string
+	for _, value := range r.Header.Values(header) {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// notModified reports whether If-None-Match matches the current entity tag,
+// using the weak comparison RFC 9110 requires for that header
+func notModified(r *http.Request, etag string) bool {
+	for _, tag := range parseETags(r, "If-None-Match") {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions returns the item versions listed in If-Match. wildcard is true
+// for "*" and when the header is absent. Weak and malformed tags never match,
+// since If-Match uses strong comparison.
+func ifMatchVersions(r *http.Request) (versions []int, wildcard bool) {
+	tags := parseETags(r, "If-Match")
+	if len(tags) == 0 {
+		return nil, true
+	}
+	for _, tag := range tags {
+		if tag == "*" {
+			return nil, true
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, false
+}
+
+// requireIfMatch writes a 428 and returns false when the server requires
+// If-Match on writes and the request has none
+func (s *server) requireIfMatch(w http.ResponseWriter, r *http.Request) bool {
+	if s.requireIfMatchHeader && r.Header.Get("If-Match") == "" {
+		problem.Error(w, r, http.StatusPreconditionRequired, "If-Match header is required; send the ETag from a previous GET")
+		return false
+	}
+	return true
+}
+
+// itemModifiedDetail explains a failed precondition on an item write
+const itemModifiedDetail = "Item has been modified; fetch it again and retry with the new ETag"
+
+// preconditionFailed writes the 412 for a write whose If-Match did not match
+func preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusPreconditionFailed, itemModifiedDetail)
+}
+
+// expectedItemVersion resolves If-Match to the version a conditional write must
+// find, or 0 for an unconditional write. On failure it writes the response and
+// returns false.
+func (s *server) expectedItemVersion(w http.ResponseWriter, r *http.Request, itemID, userID int) (int, bool) {
+	if !s.requireIfMatch(w, r) {
+		return 0, false
+	}
+
+	versions, wildcard := ifMatchVersions(r)
+	switch {
+	case wildcard:
+		return 0, true
+	case len(versions) == 1:
+		return versions[0], true
+	case len(versions) == 0:
+		preconditionFailed(w, r)
+		return 0, false
+	}
+
+	// Several tags: the write is conditional on whichever one is current
+	item, err := s.items.Get(r.Context(), userID, itemID)
+	if err != nil {
+		writeItemError(w, r, err, "Failed to query item")
+		return 0, false
+	}
+	for _, version := range versions {
+		if version == item.Version {
+			return version, true
+		}
+	}
+	preconditionFailed(w, r)
+	return 0, false
+}
+
+// itemErrorStatus maps a repository error from an item operation onto a
+// status code and problem detail
+func itemErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound, "Item not found"
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, itemModifiedDetail
+	default:
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
+
+// writeItemError maps a repository error from an item operation onto a problem
+// response, logging unexpected errors with msg
+func writeItemError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	status, detail := itemErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		logging.FromContext(r.Context()).WithError(err).Error(msg)
+	}
+	problem.Error(w, r, status, detail)
+}
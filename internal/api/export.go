@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/pkg/utils"
+)
+
+const (
+	// exportFlushItems is how many items are written between flushes
+	exportFlushItems = 100
+	// exportWriteTimeout is how long each flushed chunk of an export may take
+	// to write. The server WriteTimeout would otherwise cut long exports short.
+	exportWriteTimeout = 30 * time.Second
+)
+
+// exportColumns is the header row of CSV exports
+var exportColumns = []string{"id", "external_id", "name", "description", "labels", "attributes", "version", "created_at", "updated_at"}
+
+// itemEncoder writes exported items in one format. flush writes out anything
+// the encoder buffers.
+type itemEncoder interface {
+	begin() error
+	encode(item models.Item) error
+	flush() error
+	end() error
+}
+
+// exportFormats maps the format parameter to the content type, the file
+// extension and the encoder of each format
+var exportFormats = map[string]struct {
+	contentType string
+	extension   string
+	encoder     func(w io.Writer) itemEncoder
+}{
+	"csv":    {"text/csv; charset=utf-8", "csv", func(w io.Writer) itemEncoder { return &csvEncoder{w: csv.NewWriter(w)} }},
+	"ndjson": {"application/x-ndjson", "ndjson", func(w io.Writer) itemEncoder { return &ndjsonEncoder{enc: json.NewEncoder(w)} }},
+	"json":   {"application/json", "json", func(w io.Writer) itemEncoder { return &jsonArrayEncoder{w: w} }},
+}
+
+// csvEncoder writes one row per item under a header row, with labels and
+// attributes as JSON
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+// begin implements itemEncoder
+func (e *csvEncoder) begin() error {
+	return e.w.Write(exportColumns)
+}
+
+// encode implements itemEncoder
+func (e *csvEncoder) encode(item models.Item) error {
+	itemLabels, err := json.Marshal(item.Labels)
+	if err != nil {
+		return err
+	}
+	itemAttributes, err := json.Marshal(item.Attributes)
+	if err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(item.ID),
+		item.ExternalID,
+		item.Name,
+		item.Description,
+		string(itemLabels),
+		string(itemAttributes),
+		strconv.Itoa(item.Version),
+		item.CreatedAt.UTC().Format(time.RFC3339Nano),
+		item.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// flush implements itemEncoder
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// end implements itemEncoder
+func (e *csvEncoder) end() error {
+	return e.flush()
+}
+
+// ndjsonEncoder writes one JSON object per line
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+// begin implements itemEncoder
+func (e *ndjsonEncoder) begin() error { return nil }
+
+// encode implements itemEncoder
+func (e *ndjsonEncoder) encode(item models.Item) error {
+	return e.enc.Encode(item)
+}
+
+// flush implements itemEncoder
+func (e *ndjsonEncoder) flush() error { return nil }
+
+// end implements itemEncoder
+func (e *ndjsonEncoder) end() error { return nil }
+
+// jsonArrayEncoder writes a single JSON array, one element at a time
+type jsonArrayEncoder struct {
+	w     io.Writer
+	count int
+}
+
+// begin implements itemEncoder
+func (e *jsonArrayEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+// encode implements itemEncoder
+func (e *jsonArrayEncoder) encode(item models.Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		data = append([]byte(",\n"), data...)
+	}
+	e.count++
+	_, err = e.w.Write(data)
+	return err
+}
+
+// flush implements itemEncoder
+func (e *jsonArrayEncoder) flush() error { return nil }
+
+// end implements itemEncoder
+func (e *jsonArrayEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// flushExport extends the write deadline and flushes the response, so an
+// export reaches the client while it is still being read from the database.
+// Writers that support neither, such as test recorders, are left alone.
+func flushExport(rc *http.ResponseController) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// exportItemsHandler handles GET /api/v1/items/export. It streams every live
+// item matching the filter and sort parameters of GET /api/v1/items as a file
+// download; limit is ignored.
+func (s *server) exportItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "json"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		problem.Error(w, r, http.StatusBadRequest, "format must be csv, ndjson or json")
+		return
+	}
+
+	opts, err := parseItemListParams(r.URL.Query(), liveItems)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.OwnerID = userID
+
+	rc := http.NewResponseController(w)
+	enc := format.encoder(w)
+	started := false
+	count := 0
+
+	// The response is committed with the first item, so that query errors
+	// can still be reported as problems
+	start := func() error {
+		started = true
+		filename := "items-" + time.Now().UTC().Format("20060102T150405Z") + "." + format.extension
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		return enc.begin()
+	}
+
+	err = s.items.Export(r.Context(), opts, func(item models.Item) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(item); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushItems == 0 {
+			if err := enc.flush(); err != nil {
+				return err
+			}
+			return flushExport(rc)
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+
+	if err != nil {
+		if !started {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to export items")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		// The status has been sent, so the connection is dropped to keep a
+		// truncated export from looking complete
+		logging.FromContext(r.Context()).WithError(err).Errorf("Export failed after %d items", count)
+		panic(http.ErrAbortHandler)
+	}
+	logging.FromContext(r.Context()).Infof("Exported %d items as %s", count, name)
+}
@@ -1,291 +1,411 @@
 package api
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"kubernetes-api/internal/auth"
-	"kubernetes-api/internal/database"
-	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/health"
+	"kubernetes-api/internal/logging"
 	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/ratelimit"
+	"kubernetes-api/internal/repository"
 	"kubernetes-api/pkg/utils"
 
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
 )
 
 // DataKey is a type for data map keys to avoid staticcheck SA1029
 type DataKey string
 
-// healthHandler is the handler for the /api/health endpoint
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+// server holds the dependencies shared by the HTTP handlers
+type server struct {
+	items    repository.ItemRepository
+	users    repository.UserRepository
+	sessions repository.SessionRepository
+	apiKeys  repository.APIKeyRepository
+	schemas  repository.AttributeSchemaRepository
+	imports  repository.ImportJobRepository
+	lockout  ratelimit.Lockout
+	version  string
+
+	// requireIfMatchHeader rejects item writes without If-Match
+	requireIfMatchHeader bool
+	// maxBatchItems caps the entries of a batch request
+	maxBatchItems int
+
+	// instanceID names this instance on the import jobs it runs
+	instanceID string
+	// importsCtx is the parent of running imports, cancelled by
+	// shutdownImports; importsRunning counts them
+	importsCtx     context.Context
+	cancelImports  context.CancelFunc
+	importsRunning sync.WaitGroup
+}
+
+// healthHandler is the handler for the /api/health endpoint. It only reports
+// the process as up; dependency checks are served by /readyz.
+func (s *server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	resp := models.ApiResponse{
 		Status:  "success",
 		Message: "Service is healthy",
 		Data: map[models.DataKey]interface{}{
-			"version": "1.0.0",
-			"uptime":  time.Now().Unix(), // This should be actual uptime in a real app
+			"version":        s.version,
+			"uptime":         health.Uptime().Round(time.Second).String(),
+			"uptime_seconds": int64(health.Uptime().Seconds()),
 		},
 	}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode health response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode health response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // registerHandler handles user registration
-func registerHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) registerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate input
-	if req.Username == "" || req.Password == "" || req.Email == "" {
-		http.Error(w, "Username, password, and email are required", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
 	// Hash the password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to hash password")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to hash password")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	// Insert user into database
-	var userID int
-	err = metrics.TrackDatabaseOperation("create_user", func() error {
-		return database.DB.QueryRow(
-			"INSERT INTO users (username, password_hash, email) VALUES ($1, $2, $3) RETURNING id",
-			req.Username, passwordHash, req.Email,
-		).Scan(&userID)
-	})
-
-	if err != nil {
-		logrus.WithError(err).Error("Failed to create user")
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
-		return
-	}
-
-	// Create user object
 	user := models.User{
-		ID:           userID,
 		Username:     req.Username,
 		PasswordHash: passwordHash,
 		Email:        req.Email,
-		CreatedAt:    time.Now(),
 	}
-
-	// Generate JWT
-	token, err := auth.GenerateJWT(user)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to generate JWT")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := s.users.Create(r.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			problem.Error(w, r, http.StatusConflict, "Username or email already exists")
+			return
+		}
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to create user")
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	// Return response
-	resp := models.AuthResponse{
-		Token:   token,
-		User:    user,
-		Message: "User registered successfully",
+	// Start a session and issue tokens
+	resp, err := s.startSession(r.Context(), user)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to issue tokens")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
 	}
+	resp.Message = "User registered successfully"
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode register response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode register response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // loginHandler handles user login
-func loginHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	// Validate input
-	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
+	// Refuse locked out usernames before spending a bcrypt comparison on them
+	locked, err := s.lockout.Check(r.Context(), req.Username)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Warn("Failed to check login lockout")
+	}
+	if locked > 0 {
+		ratelimit.SetRetryAfter(w, locked)
+		problem.Error(w, r, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
 		return
 	}
 
 	// Query user from database
-	var user models.User
-	var passwordHash string
-	err := metrics.TrackDatabaseOperation("get_user", func() error {
-		return database.DB.QueryRow(
-			"SELECT id, username, password_hash, email, created_at FROM users WHERE username = $1",
-			req.Username,
-		).Scan(&user.ID, &user.Username, &passwordHash, &user.Email, &user.CreatedAt)
-	})
-
+	user, err := s.users.GetByUsername(r.Context(), req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		if errors.Is(err, repository.ErrNotFound) {
+			s.loginFailed(w, r, req.Username)
 		} else {
-			logrus.WithError(err).Error("Failed to query user")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to query user")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 		}
 		return
 	}
 
 	// Verify password
-	if !auth.CheckPasswordHash(req.Password, passwordHash) {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+		s.loginFailed(w, r, req.Username)
 		return
 	}
 
-	// Generate JWT
-	token, err := auth.GenerateJWT(user)
+	if err := s.lockout.Reset(r.Context(), req.Username); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Warn("Failed to reset login failures")
+	}
+
+	// Start a session and issue tokens
+	resp, err := s.startSession(r.Context(), user)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to issue tokens")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	resp.Message = "Login successful"
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode login response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// loginFailed records a failed login, counting unknown usernames too so the
+// lockout does not reveal which accounts exist
+func (s *server) loginFailed(w http.ResponseWriter, r *http.Request, username string) {
+	locked, err := s.lockout.Fail(r.Context(), username)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to generate JWT")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Warn("Failed to record login failure")
+	}
+	if locked > 0 {
+		logging.FromContext(r.Context()).Warnf("Locking out username %q for %s after repeated failed logins", username, locked)
+		ratelimit.SetRetryAfter(w, locked)
+		problem.Error(w, r, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return
+	}
+	problem.Error(w, r, http.StatusUnauthorized, "Invalid username or password")
+}
+
+// refreshHandler handles POST /api/v1/auth/refresh, rotating the refresh token
+func (s *server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.RefreshRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	refreshToken, refreshHash, refreshExpiresAt, err := auth.GenerateRefreshToken()
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to generate refresh token")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Consume the presented token; reuse of a consumed token revokes the session
+	session, err := s.sessions.Rotate(r.Context(), auth.HashToken(req.RefreshToken), refreshHash, refreshExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrTokenInvalid):
+			problem.Error(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		case errors.Is(err, repository.ErrTokenReused):
+			problem.Error(w, r, http.StatusUnauthorized, "Refresh token reused, session revoked")
+		default:
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to rotate refresh token")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	user, err := s.users.GetByID(r.Context(), session.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query session user")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	token, expiresAt, err := auth.GenerateJWT(user, session.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to generate JWT")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	// Return response
 	resp := models.AuthResponse{
-		Token:   token,
-		User:    user,
-		Message: "Login successful",
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		User:         user,
+		Message:      "Token refreshed",
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode login response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode refresh response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
+// logoutHandler handles POST /api/v1/auth/logout, revoking the refresh token's session
+func (s *server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.RefreshRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := s.sessions.RevokeByToken(r.Context(), auth.HashToken(req.RefreshToken)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		} else {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to revoke session")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Logged out successfully",
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode logout response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// startSession creates a new login session and issues its access and refresh tokens
+func (s *server) startSession(ctx context.Context, user models.User) (models.AuthResponse, error) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	refreshToken, refreshHash, refreshExpiresAt, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	session := models.Session{ID: sessionID, UserID: user.ID}
+	if err := s.sessions.Create(ctx, session, refreshHash, refreshExpiresAt); err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	token, expiresAt, err := auth.GenerateJWT(user, sessionID)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return models.AuthResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
 // itemsHandler handles CRUD operations for items
-func itemsHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) itemsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
-		getItemsHandler(w, r)
+		s.getItemsHandler(w, r)
 	case http.MethodPost:
-		createItemHandler(w, r)
+		s.createItemHandler(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
-// getItemsHandler handles GET /api/v1/items
-func getItemsHandler(w http.ResponseWriter, r *http.Request) {
+// getItemsHandler handles GET /api/v1/items, returning only the caller's items
+func (s *server) getItemsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listItems(w, r, liveItems)
+}
+
+// listItems writes a page of the caller's live or trashed items
+func (s *server) listItems(w http.ResponseWriter, r *http.Request, kind itemListKind) {
 	// Extract user ID from context (set by auth middleware) using custom key
 	userID, ok := r.Context().Value(utils.UserIDKey).(int)
 	if !ok {
-		http.Error(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
 		return
 	}
-	logrus.Debugf("getItemsHandler called by user ID: %d", userID) // Optional: Add logging
+	logging.FromContext(r.Context()).Debugf("listItems called by user ID: %d", userID)
 
-	// Query items from database
-	var items []models.Item
-	err := metrics.TrackDatabaseOperation("get_items", func() error {
-		rows, err := database.DB.Query("SELECT id, name, description, created_at, updated_at FROM items")
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var item models.Item
-			if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt, &item.UpdatedAt); err != nil {
-				return err
-			}
-			items = append(items, item)
-		}
-
-		return rows.Err()
-	})
+	opts, err := parseItemListParams(r.URL.Query(), kind)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.OwnerID = userID
 
+	// Query the caller's items from database
+	items, hasMore, err := s.items.List(r.Context(), opts)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to query items")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query items")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
+	// Build the paging metadata
+	pagination := models.Pagination{Limit: opts.Limit, HasMore: hasMore}
+	if hasMore {
+		pagination.NextCursor = cursorForItem(items[len(items)-1], opts)
+	}
+
 	// Return response
 	resp := models.ApiResponse{
 		Status: "success",
 		Data: map[models.DataKey]interface{}{
-			"items": items,
+			"items":      items,
+			"pagination": pagination,
 		},
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode items response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode items response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // createItemHandler handles POST /api/v1/items
-func createItemHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) createItemHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from context (set by auth middleware) using custom key
 	userID, ok := r.Context().Value(utils.UserIDKey).(int)
 	if !ok {
-		http.Error(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
 		return
 	}
-	logrus.Debugf("createItemHandler called by user ID: %d", userID) // Optional: Add logging
+	logging.FromContext(r.Context()).Debugf("createItemHandler called by user ID: %d", userID)
 
 	var req models.ItemRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate input
-	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) || !s.validateAttributes(w, r, req.Attributes) {
 		return
 	}
 
 	// Insert item into database
-	var itemID int
-	err := metrics.TrackDatabaseOperation("create_item", func() error {
-		return database.DB.QueryRow(
-			"INSERT INTO items (name, description) VALUES ($1, $2) RETURNING id",
-			req.Name, req.Description,
-		).Scan(&itemID)
-	})
-
-	if err != nil {
-		logrus.WithError(err).Error("Failed to create item")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Query the created item
-	var item models.Item
-	err = metrics.TrackDatabaseOperation("get_item", func() error {
-		return database.DB.QueryRow(
-			"SELECT id, name, description, created_at, updated_at FROM items WHERE id = $1",
-			itemID,
-		).Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt, &item.UpdatedAt)
-	})
-
-	if err != nil {
-		logrus.WithError(err).Error("Failed to query created item")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	item := models.Item{
+		OwnerID:     userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Labels:      req.Labels,
+		Attributes:  req.Attributes,
+	}
+	if err := s.items.Create(r.Context(), &item); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to create item")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	// Return response
+	setItemETag(w, item)
 	w.WriteHeader(http.StatusCreated)
 	resp := models.ApiResponse{
 		Status:  "success",
@@ -296,20 +416,20 @@ func createItemHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode item response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // itemHandler handles operations on a single item
-func itemHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) itemHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from context (set by auth middleware) using custom key
 	userID, ok := r.Context().Value(utils.UserIDKey).(int)
 	if !ok {
-		http.Error(w, "Unauthorized: User ID not found in context", http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
 		return
 	}
-	logrus.Debugf("itemHandler called by user ID: %d", userID) // Optional: Add logging
+	logging.FromContext(r.Context()).Debugf("itemHandler called by user ID: %d", userID)
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -317,40 +437,37 @@ func itemHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	itemID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest, "Invalid item ID")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		getItemHandler(w, r, itemID)
+		s.getItemHandler(w, r, itemID, userID)
 	case http.MethodPut:
-		updateItemHandler(w, r, itemID)
+		s.updateItemHandler(w, r, itemID, userID)
+	case http.MethodPatch:
+		s.patchItemHandler(w, r, itemID, userID)
 	case http.MethodDelete:
-		deleteItemHandler(w, r, itemID)
+		s.deleteItemHandler(w, r, itemID, userID)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
 // getItemHandler handles GET /api/v1/items/{id}
-func getItemHandler(w http.ResponseWriter, r *http.Request, itemID int) {
-	// Query item from database
-	var item models.Item
-	err := metrics.TrackDatabaseOperation("get_item", func() error {
-		return database.DB.QueryRow(
-			"SELECT id, name, description, created_at, updated_at FROM items WHERE id = $1",
-			itemID,
-		).Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt, &item.UpdatedAt)
-	})
-
+func (s *server) getItemHandler(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	// Query item from database; items owned by other users are reported as not found
+	item, err := s.items.Get(r.Context(), userID, itemID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Item not found", http.StatusNotFound)
-		} else {
-			logrus.WithError(err).Error("Failed to query item")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		writeItemError(w, r, err, "Failed to query item")
+		return
+	}
+
+	// Let clients revalidate a cached copy without downloading it again
+	setItemETag(w, item)
+	if notModified(r, itemETag(item)) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -363,73 +480,40 @@ func getItemHandler(w http.ResponseWriter, r *http.Request, itemID int) {
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode item response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // updateItemHandler handles PUT /api/v1/items/{id}
-func updateItemHandler(w http.ResponseWriter, r *http.Request, itemID int) {
-	var req models.ItemRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *server) updateItemHandler(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	version, ok := s.expectedItemVersion(w, r, itemID, userID)
+	if !ok {
 		return
 	}
 
-	// Validate input
-	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	var req models.ItemRequest
+	if !decodeRequest(w, r, &req) || !s.validateAttributes(w, r, req.Attributes) {
 		return
 	}
 
-	// Update item in database
-	err := metrics.TrackDatabaseOperation("update_item", func() error {
-		result, err := database.DB.Exec(
-			"UPDATE items SET name = $1, description = $2, updated_at = NOW() WHERE id = $3",
-			req.Name, req.Description, itemID,
-		)
-		if err != nil {
-			return err
-		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-
-		if rowsAffected == 0 {
-			return sql.ErrNoRows
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Item not found", http.StatusNotFound)
-		} else {
-			logrus.WithError(err).Error("Failed to update item")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
-		return
+	// Update item in database, scoped to the caller and conditional on If-Match
+	item := models.Item{
+		ID:          itemID,
+		OwnerID:     userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Labels:      req.Labels,
+		Attributes:  req.Attributes,
+		Version:     version,
 	}
-
-	// Query the updated item
-	var item models.Item
-	err = metrics.TrackDatabaseOperation("get_item", func() error {
-		return database.DB.QueryRow(
-			"SELECT id, name, description, created_at, updated_at FROM items WHERE id = $1",
-			itemID,
-		).Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt, &item.UpdatedAt)
-	})
-
-	if err != nil {
-		logrus.WithError(err).Error("Failed to query updated item")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := s.items.Update(r.Context(), &item); err != nil {
+		writeItemError(w, r, err, "Failed to update item")
 		return
 	}
 
 	// Return response
+	setItemETag(w, item)
 	resp := models.ApiResponse{
 		Status:  "success",
 		Message: "Item updated successfully",
@@ -439,50 +523,32 @@ func updateItemHandler(w http.ResponseWriter, r *http.Request, itemID int) {
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode item response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
 
 // deleteItemHandler handles DELETE /api/v1/items/{id}
-func deleteItemHandler(w http.ResponseWriter, r *http.Request, itemID int) {
-	err := metrics.TrackDatabaseOperation("delete_item", func() error {
-		result, err := database.DB.Exec("DELETE FROM items WHERE id = $1", itemID)
-		if err != nil {
-			return err
-		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-
-		if rowsAffected == 0 {
-			return sql.ErrNoRows
-		}
-
-		return nil
-	})
+func (s *server) deleteItemHandler(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	version, ok := s.expectedItemVersion(w, r, itemID, userID)
+	if !ok {
+		return
+	}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Item not found", http.StatusNotFound)
-		} else {
-			logrus.WithError(err).Error("Failed to delete item")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+	if err := s.items.Delete(r.Context(), userID, itemID, version); err != nil {
+		writeItemError(w, r, err, "Failed to delete item")
 		return
 	}
 
 	// Return response
 	resp := models.ApiResponse{
 		Status:  "success",
-		Message: "Item deleted successfully",
+		Message: "Item moved to trash",
 		Data:    map[models.DataKey]interface{}{},
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode item response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
@@ -0,0 +1,644 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/validation"
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxImportBytes caps the size of uploaded import files
+	maxImportBytes = 64 << 20
+	// maxImportLineBytes caps a single NDJSON line
+	maxImportLineBytes = 1 << 20
+	// maxImportErrors caps the failed rows listed on a job
+	maxImportErrors = 1000
+	// importProgressRows is how many rows are processed between progress saves
+	importProgressRows = 500
+	// importUploadTimeout is how long an upload may take. The server
+	// ReadTimeout would otherwise cut large uploads short.
+	importUploadTimeout = 5 * time.Minute
+	// importSaveTimeout bounds saving a job once its import has been cancelled
+	importSaveTimeout = 5 * time.Second
+	// importHeartbeatInterval is how often a running import renews its lease
+	importHeartbeatInterval = repository.ImportJobLease / 4
+)
+
+// createImportHandler handles POST /api/v1/imports. The multipart body holds
+// the file in the "file" part, and optionally the "format" (csv or ndjson,
+// taken from the file extension by default) and "dry_run" fields. The file is
+// processed in the background; the job is returned for polling.
+func (s *server) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(importUploadTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.FromContext(r.Context()).WithError(err).Warn("Failed to extend the upload deadline")
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.FromContext(r.Context()).WithError(err).Warn("Failed to extend the upload deadline")
+	}
+
+	// Leave room for the other parts and the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes+maxBodyBytes)
+	parts, err := r.MultipartReader()
+	if err != nil {
+		problem.Error(w, r, http.StatusUnsupportedMediaType, "Request body must be multipart/form-data")
+		return
+	}
+
+	job := models.ImportJob{OwnerID: userID, Status: models.ImportQueued, InstanceID: s.instanceID}
+	var path, dryRun string
+	defer func() {
+		// Once the job is queued the importer owns the file
+		if path != "" {
+			os.Remove(path)
+		}
+	}()
+
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			if path != "" {
+				problem.Error(w, r, http.StatusBadRequest, "Only one file can be imported at a time")
+				return
+			}
+			job.Filename = filepath.Base(part.FileName())
+			if path, job.BytesTotal, err = saveUpload(part); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) || job.BytesTotal > maxImportBytes {
+					problem.Error(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", maxImportBytes))
+					return
+				}
+				logging.FromContext(r.Context()).WithError(err).Error("Failed to save import upload")
+				problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+		case "format", "dry_run":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				writeDecodeError(w, r, err)
+				return
+			}
+			if part.FormName() == "format" {
+				job.Format = strings.TrimSpace(string(value))
+			} else {
+				dryRun = strings.TrimSpace(string(value))
+			}
+		}
+	}
+
+	var errs validation.Errors
+	if path == "" {
+		errs = append(errs, validation.FieldError{Field: "file", Rule: "required", Message: "is required"})
+	}
+	if job.Format == "" {
+		switch strings.ToLower(filepath.Ext(job.Filename)) {
+		case ".csv":
+			job.Format = "csv"
+		case ".ndjson", ".jsonl":
+			job.Format = "ndjson"
+		}
+	}
+	if job.Format != "csv" && job.Format != "ndjson" {
+		errs = append(errs, validation.FieldError{Field: "format", Rule: "oneof", Message: "must be csv or ndjson"})
+	}
+	if dryRun != "" {
+		if job.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			errs = append(errs, validation.FieldError{Field: "dry_run", Rule: "type", Message: "must be a boolean"})
+		}
+	}
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs)
+		return
+	}
+
+	if err := s.imports.Create(r.Context(), &job); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to create import job")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// The import outlives the request, so it runs under the server's import
+	// context and only keeps the logger of the request
+	logger := logging.FromContext(r.Context()).WithField("import_id", job.ID)
+	s.importsRunning.Add(1)
+	go s.runImport(logging.NewContext(s.importsCtx, logger), job, path)
+	path = ""
+	logger.Infof("Import of %s queued", job.Filename)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/imports/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	writeImportJob(w, r, "Import queued", job)
+}
+
+// getImportHandler handles GET /api/v1/imports/{id}
+func (s *server) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	job, err := s.imports.Get(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusNotFound, "Import not found")
+			return
+		}
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query import job")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeImportJob(w, r, "", job)
+}
+
+// writeImportJob writes a single import job response
+func writeImportJob(w http.ResponseWriter, r *http.Request, message string, job models.ImportJob) {
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: message,
+		Data: map[models.DataKey]interface{}{
+			"import": job,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode import response")
+	}
+}
+
+// saveUpload copies an uploaded file to a temporary file and returns its path
+// and size. Files larger than maxImportBytes are refused.
+func saveUpload(src io.Reader) (string, int64, error) {
+	f, err := os.CreateTemp("", "item-import-*")
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(src, maxImportBytes+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxImportBytes {
+		err = errors.New("file too large")
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", n, err
+	}
+	return f.Name(), n, nil
+}
+
+// importRecord is one row read from an import file, or the reason it could
+// not be read
+type importRecord struct {
+	line int
+	row  models.ImportRow
+	err  error
+}
+
+// importReader reads the rows of an import file. Errors returned by next stop
+// the import; rows that cannot be parsed carry their error instead.
+type importReader interface {
+	next() (importRecord, error)
+}
+
+// csvImportReader reads CSV files with a header row. The external_id, name,
+// description, labels and attributes columns are read, with labels and
+// attributes as JSON objects; other columns, such as those added by exports,
+// are ignored.
+type csvImportReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVImportReader reads the header row of a CSV file
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid header row: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets may start the file with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("header row has no name column")
+	}
+	return &csvImportReader{r: cr, columns: columns}, nil
+}
+
+// next implements importReader
+func (c *csvImportReader) next() (importRecord, error) {
+	fields, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return importRecord{line: parseErr.StartLine, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return importRecord{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+
+	rec := importRecord{line: line}
+	rec.row.ExternalID = field("external_id")
+	rec.row.Name = field("name")
+	rec.row.Description = field("description")
+	if v := field("labels"); v != "" {
+		if err := json.Unmarshal([]byte(v), &rec.row.Labels); err != nil {
+			rec.err = errors.New("labels must be a JSON object of strings")
+		}
+	}
+	if v := field("attributes"); v != "" && rec.err == nil {
+		if err := json.Unmarshal([]byte(v), &rec.row.Attributes); err != nil {
+			rec.err = errors.New("attributes must be a JSON object")
+		}
+	}
+	return rec, nil
+}
+
+// ndjsonImportReader reads files with one JSON item per line, in the shape of
+// an item request with an optional external_id. Blank lines are skipped and
+// unknown fields ignored.
+type ndjsonImportReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// newNDJSONImportReader reads an NDJSON file
+func newNDJSONImportReader(r io.Reader) *ndjsonImportReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), maxImportLineBytes)
+	return &ndjsonImportReader{s: s}
+}
+
+// next implements importReader
+func (n *ndjsonImportReader) next() (importRecord, error) {
+	for n.s.Scan() {
+		n.line++
+		text := bytes.TrimSpace(n.s.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		rec := importRecord{line: n.line}
+		if err := json.Unmarshal(text, &rec.row); err != nil {
+			rec.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		return rec, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return importRecord{}, fmt.Errorf("line %d is longer than %d bytes", n.line+1, maxImportLineBytes)
+		}
+		return importRecord{}, err
+	}
+	return importRecord{}, io.EOF
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// importer applies the rows of one import job
+type importer struct {
+	s       *server
+	job     *models.ImportJob
+	schemas map[string]*attributes.Schema
+	// simulated holds the items a dry run would have written, by external ID,
+	// so later rows for the same item are counted as if they had been
+	simulated map[string]models.Item
+}
+
+// shutdownImports cancels the running imports and waits until they have
+// recorded that they were interrupted, or until ctx is done
+func (s *server) shutdownImports(ctx context.Context) error {
+	s.cancelImports()
+
+	done := make(chan struct{})
+	go func() {
+		s.importsRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for imports: %w", ctx.Err())
+	}
+}
+
+// runImport processes an import job in the background and removes the file
+// once done. Rows are applied one at a time; errors other than invalid rows
+// stop the job, which can then be run again. Cancelling ctx stops the job as
+// interrupted. The job's lease is renewed while it runs, and the import stops
+// without saving if the job has been failed as abandoned in the meantime.
+func (s *server) runImport(ctx context.Context, job models.ImportJob, path string) {
+	defer s.importsRunning.Done()
+	defer os.Remove(path)
+	logger := logging.FromContext(ctx)
+
+	ctx, stop := context.WithCancelCause(ctx)
+	heartbeatDone := make(chan struct{})
+	defer func() {
+		stop(nil)
+		<-heartbeatDone
+	}()
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(importHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.imports.Heartbeat(ctx, job.ID); errors.Is(err, repository.ErrImportEnded) {
+					stop(err)
+				} else if err != nil && ctx.Err() == nil {
+					logger.WithError(err).Warn("Failed to renew import lease")
+				}
+			}
+		}
+	}()
+
+	save := func() {
+		// The job is still saved once the import has been cancelled
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), importSaveTimeout)
+		defer cancel()
+		if err := s.imports.Update(saveCtx, &job); errors.Is(err, repository.ErrImportEnded) {
+			stop(err)
+		} else if err != nil {
+			logger.WithError(err).Error("Failed to save import job")
+		}
+	}
+	fail := func(msg string, err error) {
+		if errors.Is(context.Cause(ctx), repository.ErrImportEnded) {
+			logger.Warn("Import stopped, its job has already ended")
+			return
+		}
+		if ctx.Err() != nil {
+			msg = models.ImportInterrupted
+		}
+		logger.WithError(err).Error("Import failed")
+		now := time.Now()
+		job.Status, job.Error, job.FinishedAt = models.ImportFailed, msg, &now
+		save()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fail("Internal server error", fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	now := time.Now()
+	job.Status, job.StartedAt = models.ImportRunning, &now
+	save()
+
+	f, err := os.Open(path)
+	if err != nil {
+		fail("Internal server error", err)
+		return
+	}
+	defer f.Close()
+	counter := &countingReader{Reader: f}
+
+	var rows importReader
+	if job.Format == "csv" {
+		csvRows, err := newCSVImportReader(counter)
+		if err != nil {
+			fail(err.Error(), err)
+			return
+		}
+		rows = csvRows
+	} else {
+		rows = newNDJSONImportReader(counter)
+	}
+
+	imp := &importer{s: s, job: &job, simulated: map[string]models.Item{}}
+	if imp.schemas, err = s.attributeSchemas(ctx); err != nil {
+		fail("Internal server error", err)
+		return
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			fail(models.ImportInterrupted, err)
+			return
+		}
+
+		rec, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fail("Reading the file failed: "+err.Error(), err)
+			return
+		}
+
+		job.RowsRead++
+		if err := imp.apply(ctx, rec); err != nil {
+			fail(fmt.Sprintf("Line %d could not be imported; the import can be run again", rec.line), err)
+			return
+		}
+
+		if job.RowsRead%importProgressRows == 0 {
+			job.BytesRead = counter.n
+			save()
+		}
+	}
+
+	now = time.Now()
+	job.Status, job.BytesRead, job.FinishedAt = models.ImportCompleted, counter.n, &now
+	save()
+	if errors.Is(context.Cause(ctx), repository.ErrImportEnded) {
+		logger.Warn("Import finished, but its job had already ended")
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"created": job.Created, "updated": job.Updated, "unchanged": job.Unchanged, "failed": job.Failed,
+	}).Infof("Import of %d rows completed", job.RowsRead)
+}
+
+// reject records a row that was not applied
+func (imp *importer) reject(rec importRecord, msg string) {
+	imp.job.Failed++
+	if len(imp.job.Errors) < maxImportErrors {
+		imp.job.Errors = append(imp.job.Errors, models.ImportError{Line: rec.line, ExternalID: rec.row.ExternalID, Message: msg})
+	}
+}
+
+// apply validates a row and creates or updates its item, or in a dry run only
+// counts what would change. Returned errors stop the import.
+func (imp *importer) apply(ctx context.Context, rec importRecord) error {
+	if rec.err != nil {
+		imp.reject(rec, rec.err.Error())
+		return nil
+	}
+
+	row := rec.row
+	errs := append(validation.Struct(&row), validation.Struct(&row.ItemRequest)...)
+	if len(errs) == 0 {
+		errs = schemaErrors(imp.schemas, row.Attributes)
+	}
+	if len(errs) > 0 {
+		imp.reject(rec, errs.Error())
+		return nil
+	}
+
+	item := models.Item{
+		OwnerID:     imp.job.OwnerID,
+		ExternalID:  row.ExternalID,
+		Name:        row.Name,
+		Description: row.Description,
+		Labels:      row.Labels,
+		Attributes:  row.Attributes,
+	}
+
+	var current models.Item
+	var err error
+	if row.ExternalID == "" {
+		err = repository.ErrNotFound
+	} else if simulated, ok := imp.simulated[row.ExternalID]; ok {
+		current = simulated
+	} else {
+		current, err = imp.s.items.GetByExternalID(ctx, imp.job.OwnerID, row.ExternalID)
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		if imp.job.DryRun {
+			imp.simulate(item)
+		} else if err := imp.s.items.Create(ctx, &item); errors.Is(err, repository.ErrConflict) {
+			imp.reject(rec, "external_id is already used by another item")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		imp.job.Created++
+		return nil
+	case err != nil:
+		return err
+	case current.DeletedAt != nil:
+		imp.reject(rec, "the item with this external_id is in the trash")
+		return nil
+	}
+
+	// Rows without labels or attributes keep those of the item, like a PUT
+	updated := current
+	updated.Name, updated.Description = item.Name, item.Description
+	if item.Labels != nil {
+		updated.Labels = item.Labels
+	}
+	if item.Attributes != nil {
+		updated.Attributes = item.Attributes
+	}
+	if reflect.DeepEqual(updated, current) {
+		imp.job.Unchanged++
+		return nil
+	}
+
+	if imp.job.DryRun {
+		imp.simulate(updated)
+	} else {
+		item.ID, item.Version = current.ID, current.Version
+		if err := imp.s.items.Update(ctx, &item); errors.Is(err, repository.ErrVersionMismatch) || errors.Is(err, repository.ErrNotFound) {
+			imp.reject(rec, "the item was modified during the import")
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	imp.job.Updated++
+	return nil
+}
+
+// simulate remembers the item a dry run would have written
+func (imp *importer) simulate(item models.Item) {
+	if item.ExternalID == "" {
+		return
+	}
+	if item.Labels == nil {
+		item.Labels = map[string]string{}
+	}
+	if item.Attributes == nil {
+		item.Attributes = map[string]interface{}{}
+	}
+	imp.simulated[item.ExternalID] = item
+}
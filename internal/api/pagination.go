@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/labels"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/repository"
+)
+
+const (
+	// defaultPageLimit is used when the client does not pass a limit
+	defaultPageLimit = 20
+	// maxPageLimit caps the number of items returned in a single page
+	maxPageLimit = 100
+)
+
+// itemListKind selects which items a listing endpoint returns
+type itemListKind int
+
+const (
+	liveItems itemListKind = iota
+	trashedItems
+	searchedItems
+)
+
+// pageCursor is the opaque keyset position handed back to clients as next_cursor
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// sortParam returns the sort parameter in its query string form, e.g. "-created_at"
+func sortParam(opts repository.ItemListOptions) string {
+	if opts.SortDesc {
+		return "-" + opts.SortField
+	}
+	return opts.SortField
+}
+
+// parseItemListParams parses and validates pagination, filter and sort
+// parameters. The trash is listed most recently deleted first by default, and
+// search results by relevance to the q parameter.
+func parseItemListParams(query url.Values, kind itemListKind) (repository.ItemListOptions, error) {
+	params := repository.ItemListOptions{
+		Limit:     defaultPageLimit,
+		SortField: "created_at",
+	}
+	sortFields := repository.ItemSortFields
+	switch kind {
+	case trashedItems:
+		params.Trashed = true
+		params.SortField, params.SortDesc = "deleted_at", true
+		sortFields = repository.TrashSortFields
+	case searchedItems:
+		params.Search = strings.TrimSpace(query.Get("q"))
+		if len(repository.SearchTerms(params.Search)) == 0 {
+			return params, errors.New("q must contain at least one word to search for")
+		}
+		params.SortField, params.SortDesc = "relevance", true
+		sortFields = repository.SearchSortFields
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return params, errors.New("limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		params.Limit = limit
+	}
+
+	if v := query.Get("sort"); v != "" {
+		field := strings.TrimPrefix(v, "-")
+		if !sortFields[field] {
+			return params, fmt.Errorf("unsupported sort field %q", field)
+		}
+		params.SortField = field
+		params.SortDesc = strings.HasPrefix(v, "-")
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil || cursor.Sort != sortParam(params) {
+			return params, errors.New("invalid cursor")
+		}
+		after := &repository.ItemCursor{Value: cursor.Value, ID: cursor.ID}
+		switch params.SortField {
+		case "name":
+			// Names are compared as the strings they are
+		case "relevance":
+			rank, err := strconv.ParseFloat(cursor.Value, 64)
+			if err != nil {
+				return params, errors.New("invalid cursor")
+			}
+			after.Value = rank
+		default:
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return params, errors.New("invalid cursor")
+			}
+			after.Value = t
+		}
+		params.After = after
+	}
+
+	params.NamePrefix = query.Get("name_prefix")
+
+	if v := query.Get("labelSelector"); v != "" {
+		selector, err := labels.Parse(v)
+		if err != nil {
+			return params, fmt.Errorf("invalid labelSelector: %v", err)
+		}
+		params.LabelSelector = selector
+	}
+
+	for _, expr := range attributeFilterExprs(query) {
+		filter, err := attributes.ParseFilter(expr)
+		if err != nil {
+			return params, fmt.Errorf("invalid attribute filter: %v", err)
+		}
+		params.AttributeFilters = append(params.AttributeFilters, filter)
+	}
+
+	timeFilters := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_after", &params.CreatedAfter},
+		{"created_before", &params.CreatedBefore},
+		{"updated_after", &params.UpdatedAfter},
+		{"updated_before", &params.UpdatedBefore},
+	}
+	for _, f := range timeFilters {
+		v := query.Get(f.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return params, fmt.Errorf("%s must be an RFC 3339 timestamp", f.name)
+		}
+		*f.target = &t
+	}
+
+	return params, nil
+}
+
+// attributeFilterExprs reassembles attribute filters such as
+// attributes.priority>=3 from the query. The query parser splits them at the
+// first '=', leaving "attributes.priority>" as the key and "3" as the value,
+// while filters without '=', or with it escaped, end up whole in the key.
+func attributeFilterExprs(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if strings.HasPrefix(key, "attributes.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var exprs []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if value == "" && strings.ContainsAny(strings.TrimRight(key, "<>!="), "<>=") {
+				exprs = append(exprs, key)
+				continue
+			}
+			exprs = append(exprs, key+"="+value)
+		}
+	}
+	return exprs
+}
+
+// encodeCursor serializes a cursor into an opaque URL-safe token
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token produced by encodeCursor
+func decodeCursor(token string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// cursorForItem builds the cursor pointing just past the given item
+func cursorForItem(item models.Item, params repository.ItemListOptions) string {
+	var value string
+	switch params.SortField {
+	case "name":
+		value = item.Name
+	case "updated_at":
+		value = item.UpdatedAt.Format(time.RFC3339Nano)
+	case "deleted_at":
+		value = item.DeletedAt.Format(time.RFC3339Nano)
+	default:
+		value = item.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return encodeCursor(pageCursor{Sort: sortParam(params), Value: value, ID: item.ID})
+}
+
+// cursorForResult builds the cursor pointing just past the given search result
+func cursorForResult(result models.ItemSearchResult, params repository.ItemListOptions) string {
+	if params.SortField != "relevance" {
+		return cursorForItem(result.Item, params)
+	}
+	value := strconv.FormatFloat(result.Rank, 'g', -1, 64)
+	return encodeCursor(pageCursor{Sort: sortParam(params), Value: value, ID: result.ID})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/validation"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Patch media types accepted by PATCH /api/v1/items/{id}
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// acceptPatch is the Accept-Patch header value advertising the patch formats
+const acceptPatch = mergePatchType + ", " + jsonPatchType
+
+// patchRejection is returned by a patch callback to abort the transaction.
+// It carries the response that explains why the patch was refused.
+type patchRejection struct {
+	write func(w http.ResponseWriter, r *http.Request)
+}
+
+// Error implements error
+func (e *patchRejection) Error() string {
+	return "patch rejected"
+}
+
+// patchFunc transforms the patchable fields of an item, as JSON
+type patchFunc func(doc []byte) ([]byte, error)
+
+// patchItemHandler handles PATCH /api/v1/items/{id}. The patch is applied to
+// the item's name, description, labels and attributes inside a transaction, so concurrent writers
+// cannot interleave, and the result is validated like a PUT body.
+func (s *server) patchItemHandler(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	version, ok := s.expectedItemVersion(w, r, itemID, userID)
+	if !ok {
+		return
+	}
+
+	patch, ok := decodePatch(w, r)
+	if !ok {
+		return
+	}
+
+	item := models.Item{ID: itemID, OwnerID: userID, Version: version}
+	err := s.items.Patch(r.Context(), &item, func(current *models.Item) error {
+		if err := applyItemPatch(current, patch); err != nil {
+			return err
+		}
+		errs, err := s.checkAttributeSchemas(r.Context(), current.Attributes)
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			return &patchRejection{func(w http.ResponseWriter, r *http.Request) {
+				problem.Validation(w, r, errs)
+			}}
+		}
+		return nil
+	})
+	var rejection *patchRejection
+	if errors.As(err, &rejection) {
+		rejection.write(w, r)
+		return
+	}
+	if err != nil {
+		writeItemError(w, r, err, "Failed to patch item")
+		return
+	}
+
+	// Return response
+	setItemETag(w, item)
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Item updated successfully",
+		Data: map[models.DataKey]interface{}{
+			"item": item,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// decodePatch reads the patch document in the format named by Content-Type.
+// On failure it writes the problem response and returns false.
+func decodePatch(w http.ResponseWriter, r *http.Request) (patchFunc, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchType && mediaType != jsonPatchType) {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		problem.Error(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchType+" or "+jsonPatchType)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return nil, false
+	}
+
+	if mediaType == mergePatchType {
+		if !json.Valid(body) {
+			problem.Error(w, r, http.StatusBadRequest, "Invalid merge patch document")
+			return nil, false
+		}
+		return func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}, true
+	}
+
+	ops, err := jsonpatch.DecodePatch(body)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid JSON patch document")
+		return nil, false
+	}
+	return ops.Apply, true
+}
+
+// applyItemPatch applies patch to the patchable fields of item and validates
+// the result. Failures are returned as a *patchRejection.
+func applyItemPatch(item *models.Item, patch patchFunc) error {
+	doc, err := json.Marshal(models.ItemRequest{Name: item.Name, Description: item.Description, Labels: item.Labels, Attributes: item.Attributes})
+	if err != nil {
+		return err
+	}
+
+	patched, err := patch(doc)
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return &patchRejection{func(w http.ResponseWriter, r *http.Request) {
+			problem.Error(w, r, http.StatusConflict, "Patch test operation failed")
+		}}
+	case err != nil:
+		detail := "Patch cannot be applied to the item: " + err.Error()
+		return &patchRejection{func(w http.ResponseWriter, r *http.Request) {
+			problem.Error(w, r, http.StatusUnprocessableEntity, detail)
+		}}
+	}
+
+	// The patched document must still be a valid item request
+	var req models.ItemRequest
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return &patchRejection{func(w http.ResponseWriter, r *http.Request) {
+			writeDecodeError(w, r, err)
+		}}
+	}
+	if errs := validation.Struct(&req); len(errs) > 0 {
+		return &patchRejection{func(w http.ResponseWriter, r *http.Request) {
+			problem.Validation(w, r, errs)
+		}}
+	}
+
+	item.Name = req.Name
+	item.Description = req.Description
+	item.Labels = req.Labels
+	item.Attributes = req.Attributes
+	return nil
+}
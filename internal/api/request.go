@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/validation"
+)
+
+// maxBodyBytes caps the size of JSON request bodies
+const maxBodyBytes = 1 << 20
+
+// decodeRequest strictly decodes the JSON body into dst and validates it
+// against its `validate` tags. On failure it writes the problem response and
+// returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return decodeRequestLimit(w, r, dst, maxBodyBytes)
+}
+
+// decodeRequestLimit is decodeRequest for bodies of up to limit bytes
+func decodeRequestLimit(w http.ResponseWriter, r *http.Request, dst interface{}, limit int64) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+	// The body must hold exactly one JSON value
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		problem.Error(w, r, http.StatusBadRequest, "Request body must contain a single JSON object")
+		return false
+	}
+
+	if errs := validation.Struct(dst); len(errs) > 0 {
+		problem.Validation(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// writeDecodeError maps a JSON decoding error onto a problem response
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		problem.Error(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+	case errors.As(err, &typeErr):
+		problem.Validation(w, r, validation.Errors{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		problem.Validation(w, r, validation.Errors{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: "is not a recognized field",
+		}})
+	default:
+		problem.Error(w, r, http.StatusBadRequest, "Invalid request body")
+	}
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// revisionVars reads the caller and the item and revision IDs of a revision
+// route. On failure it writes the problem response and returns false.
+func revisionVars(w http.ResponseWriter, r *http.Request) (userID, itemID, revision int, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok = r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return 0, 0, 0, false
+	}
+
+	vars := mux.Vars(r)
+	itemID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid item ID")
+		return 0, 0, 0, false
+	}
+	if v, present := vars["rev"]; present {
+		if revision, err = strconv.Atoi(v); err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "Invalid revision")
+			return 0, 0, 0, false
+		}
+	}
+	return userID, itemID, revision, true
+}
+
+// writeRevisionError maps a repository error from a revision lookup onto a
+// problem response
+func writeRevisionError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Error(w, r, http.StatusNotFound, "Item or revision not found")
+		return
+	}
+	writeItemError(w, r, err, "Failed to query item revisions")
+}
+
+// listRevisionsHandler handles GET /api/v1/items/{id}/revisions, newest first
+func (s *server) listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, itemID, _, ok := revisionVars(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			problem.Error(w, r, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	before := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil || cursor.Sort != "-revision" {
+			problem.Error(w, r, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		before = cursor.ID
+	}
+
+	revisions, hasMore, err := s.items.ListRevisions(r.Context(), userID, itemID, before, limit)
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	pagination := models.Pagination{Limit: limit, HasMore: hasMore}
+	if hasMore {
+		pagination.NextCursor = encodeCursor(pageCursor{Sort: "-revision", ID: revisions[len(revisions)-1].Revision})
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"revisions":  revisions,
+			"pagination": pagination,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode revisions response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// getRevisionHandler handles GET /api/v1/items/{id}/revisions/{rev}
+func (s *server) getRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, itemID, revision, ok := revisionVars(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := s.items.GetRevision(r.Context(), userID, itemID, revision)
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"revision": rev,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode revision response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// diffRevisionsHandler handles GET /api/v1/items/{id}/diff?from=&to=, listing
+// the fields that differ between two revisions. to defaults to the latest.
+func (s *server) diffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, itemID, _, ok := revisionVars(w, r)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+	fromRev, err := s.items.GetRevision(r.Context(), userID, itemID, from)
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	var toRev models.ItemRevision
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "to must be a revision number")
+			return
+		}
+		if toRev, err = s.items.GetRevision(r.Context(), userID, itemID, to); err != nil {
+			writeRevisionError(w, r, err)
+			return
+		}
+	} else {
+		latest, _, err := s.items.ListRevisions(r.Context(), userID, itemID, 0, 1)
+		if err == nil && len(latest) == 0 {
+			// The item was purged since its revision was read
+			err = repository.ErrNotFound
+		}
+		if err != nil {
+			writeRevisionError(w, r, err)
+			return
+		}
+		toRev = latest[0]
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"from":    fromRev.Revision,
+			"to":      toRev.Revision,
+			"changes": diffStates(fromRev.After, toRev.After),
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode diff response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// revertItemHandler handles POST /api/v1/items/{id}/revisions/{rev}/revert,
+// restoring the name, description, labels and attributes of an earlier
+// revision as a new revision
+func (s *server) revertItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, itemID, revision, ok := revisionVars(w, r)
+	if !ok {
+		return
+	}
+
+	version, ok := s.expectedItemVersion(w, r, itemID, userID)
+	if !ok {
+		return
+	}
+
+	item := models.Item{ID: itemID, OwnerID: userID, Version: version}
+	if err := s.items.Revert(r.Context(), &item, revision); err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	// Return response
+	setItemETag(w, item)
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Item reverted to revision " + strconv.Itoa(revision),
+		Data: map[models.DataKey]interface{}{
+			"item": item,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// diffStates lists the fields that differ between two item states
+func diffStates(from, to models.ItemState) []models.ItemChange {
+	changes := []models.ItemChange{}
+	if from.Name != to.Name {
+		changes = append(changes, models.ItemChange{Field: "name", From: from.Name, To: to.Name})
+	}
+	if from.Description != to.Description {
+		changes = append(changes, models.ItemChange{Field: "description", From: from.Description, To: to.Description})
+	}
+	changes = append(changes, diffLabels(from.Labels, to.Labels)...)
+	changes = append(changes, diffAttributes(from.Attributes, to.Attributes)...)
+	if from.Deleted != to.Deleted {
+		changes = append(changes, models.ItemChange{Field: "deleted", From: from.Deleted, To: to.Deleted})
+	}
+	return changes
+}
+
+// diffLabels lists the labels added, changed or removed between two label
+// sets, in key order. Absent labels are reported as null.
+func diffLabels(from, to map[string]string) []models.ItemChange {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []models.ItemChange
+	for _, key := range keys {
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		if inFrom == inTo && fromValue == toValue {
+			continue
+		}
+		change := models.ItemChange{Field: "labels." + key}
+		if inFrom {
+			change.From = fromValue
+		}
+		if inTo {
+			change.To = toValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// diffAttributes lists the attribute namespaces whose values differ between
+// two states, in namespace order. Absent namespaces are reported as null.
+func diffAttributes(from, to map[string]interface{}) []models.ItemChange {
+	namespaces := make([]string, 0, len(from)+len(to))
+	for namespace := range from {
+		namespaces = append(namespaces, namespace)
+	}
+	for namespace := range to {
+		if _, ok := from[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var changes []models.ItemChange
+	for _, namespace := range namespaces {
+		if !reflect.DeepEqual(from[namespace], to[namespace]) {
+			changes = append(changes, models.ItemChange{Field: "attributes." + namespace, From: from[namespace], To: to[namespace]})
+		}
+	}
+	return changes
+}
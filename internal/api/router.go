@@ -1,76 +1,230 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
+	"strconv"
+	"time"
 
 	"kubernetes-api/internal/auth"
+	"kubernetes-api/internal/health"
+	"kubernetes-api/internal/logging"
 	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/ratelimit"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/tracing"
+	"kubernetes-api/pkg/utils"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/sirupsen/logrus"
 )
 
-// LogKey is a type for log field map keys to avoid staticcheck SA1029
-type LogKey string
+// Dependencies holds the collaborators the HTTP handlers are built from
+type Dependencies struct {
+	Items    repository.ItemRepository
+	Users    repository.UserRepository
+	Sessions repository.SessionRepository
+	APIKeys  repository.APIKeyRepository
+	// AttributeSchemas holds the JSON Schemas item attributes are validated against
+	AttributeSchemas repository.AttributeSchemaRepository
+	// Imports tracks the jobs importing items from uploaded files
+	Imports repository.ImportJobRepository
+	// InstanceID names this server instance on the import jobs it runs
+	InstanceID string
+
+	// RateLimiter applies RateLimits; nil disables rate limiting
+	RateLimiter *ratelimit.Limiter
+	RateLimits  ratelimit.Config
+	// Lockout tracks failed logins; an in-memory lockout is used when nil
+	Lockout ratelimit.Lockout
+
+	// Health holds the probe checks; an empty registry is used when nil
+	Health *health.Registry
+	// Version is reported by the health endpoint
+	Version string
+
+	// RequireIfMatch rejects item updates and deletes that do not send If-Match
+	// with 428 Precondition Required
+	RequireIfMatch bool
+	// MaxBatchItems caps the entries of a batch request; 0 uses the default
+	MaxBatchItems int
+}
+
+// SetupRouter sets up the HTTP router with all endpoints. The returned
+// shutdown function interrupts the imports still running and waits for them
+// to record it, until ctx is done; call it before closing the database.
+func SetupRouter(deps Dependencies) (http.Handler, func(ctx context.Context) error) {
+	s := &server{
+		items:    deps.Items,
+		users:    deps.Users,
+		sessions: deps.Sessions,
+		apiKeys:  deps.APIKeys,
+		schemas:  deps.AttributeSchemas,
+		imports:  deps.Imports,
+		lockout:  deps.Lockout,
+		version:  deps.Version,
+
+		instanceID:           deps.InstanceID,
+		requireIfMatchHeader: deps.RequireIfMatch,
+		maxBatchItems:        deps.MaxBatchItems,
+	}
+	if s.lockout == nil {
+		s.lockout = ratelimit.NewMemoryLockout(deps.RateLimits.Lockout)
+	}
+	if s.maxBatchItems <= 0 {
+		s.maxBatchItems = defaultMaxBatchItems
+	}
+	s.importsCtx, s.cancelImports = context.WithCancel(context.Background())
+
+	checks := deps.Health
+	if checks == nil {
+		checks = health.NewRegistry(2 * time.Second)
+	}
 
-// SetupRouter sets up the HTTP router with all endpoints
-func SetupRouter() http.Handler {
 	r := mux.NewRouter()
 
 	// Add middleware
 	r.Use(metrics.MetricsMiddleware)
-	r.Use(loggingMiddleware)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+
+	// Public auth endpoints are limited per client IP, protected ones per user.
+	// Login and register have buckets of their own.
+	perIP := deps.RateLimiter.Middleware("auth", deps.RateLimits.Auth, ratelimit.ByIP)
+	loginPerIP := deps.RateLimiter.Middleware("login", deps.RateLimits.Login, ratelimit.ByIP)
+	registerPerIP := deps.RateLimiter.Middleware("register", deps.RateLimits.Register, ratelimit.ByIP)
+	perUser := deps.RateLimiter.Middleware("api", deps.RateLimits.API, ratelimit.ByUser)
 
 	// Public endpoints
-	r.HandleFunc("/api/health", healthHandler).Methods(http.MethodGet)
-	r.HandleFunc("/api/v1/auth/register", registerHandler).Methods(http.MethodPost)
-	r.HandleFunc("/api/v1/auth/login", loginHandler).Methods(http.MethodPost)
+	r.HandleFunc("/api/health", s.healthHandler).Methods(http.MethodGet)
+
+	// Kubernetes probes
+	r.HandleFunc("/livez", checks.Handler(health.Liveness)).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", checks.Handler(health.Readiness)).Methods(http.MethodGet)
+	r.HandleFunc("/startupz", checks.Handler(health.Startup)).Methods(http.MethodGet)
+
+	r.Handle("/api/v1/auth/register", registerPerIP(http.HandlerFunc(s.registerHandler))).Methods(http.MethodPost)
+	r.Handle("/api/v1/auth/login", loginPerIP(http.HandlerFunc(s.loginHandler))).Methods(http.MethodPost)
+	r.Handle("/api/v1/auth/refresh", perIP(http.HandlerFunc(s.refreshHandler))).Methods(http.MethodPost)
+	r.Handle("/api/v1/auth/logout", perIP(http.HandlerFunc(s.logoutHandler))).Methods(http.MethodPost)
+
+	// Public signing keys for verifying issued tokens
+	r.HandleFunc("/.well-known/jwks.json", auth.JWKSHandler).Methods(http.MethodGet)
 
 	// Metrics endpoint for Prometheus
 	r.Handle("/metrics", promhttp.Handler())
 
 	// Authenticated endpoints
 	apiV1 := r.PathPrefix("/api/v1").Subrouter()
-	apiV1.Use(auth.AuthMiddleware)
+	apiV1.Use(auth.AuthMiddleware(deps.Sessions, deps.APIKeys))
+	apiV1.Use(perUser)
 
-	// Items endpoints
-	apiV1.HandleFunc("/items", itemsHandler).Methods(http.MethodGet, http.MethodPost)
-	apiV1.HandleFunc("/items/{id:[0-9]+}", itemHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+	// Role and API key scope checks applied per route
+	readers := allow(models.ScopeItemsRead, models.RoleViewer, models.RoleEditor, models.RoleAdmin)
+	writers := allow(models.ScopeItemsWrite, models.RoleEditor, models.RoleAdmin)
+	admins := allow(models.ScopeUsersManage, models.RoleAdmin)
 
-	// Handle 404
-	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	// Items endpoints
+	apiV1.Handle("/items", readers(http.HandlerFunc(s.itemsHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items", writers(http.HandlerFunc(s.itemsHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/items/{id:[0-9]+}", readers(http.HandlerFunc(s.itemHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/{id:[0-9]+}", writers(http.HandlerFunc(s.itemHandler))).Methods(http.MethodPut, http.MethodPatch, http.MethodDelete)
+	apiV1.Handle("/items/{id:[0-9]+}/restore", writers(http.HandlerFunc(s.restoreItemHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/items/{id:[0-9]+}/revisions", readers(http.HandlerFunc(s.listRevisionsHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/{id:[0-9]+}/revisions/{rev:[0-9]+}", readers(http.HandlerFunc(s.getRevisionHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/{id:[0-9]+}/revisions/{rev:[0-9]+}/revert", writers(http.HandlerFunc(s.revertItemHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/items/{id:[0-9]+}/diff", readers(http.HandlerFunc(s.diffRevisionsHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/search", readers(http.HandlerFunc(s.searchItemsHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/export", readers(http.HandlerFunc(s.exportItemsHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/trash", readers(http.HandlerFunc(s.trashHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/items/trash/{id:[0-9]+}", writers(http.HandlerFunc(s.deleteTrashedItemHandler))).Methods(http.MethodDelete)
+	apiV1.Handle("/items:batchCreate", writers(http.HandlerFunc(s.batchCreateItemsHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/items:batchUpdate", writers(http.HandlerFunc(s.batchUpdateItemsHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/items:batchDelete", writers(http.HandlerFunc(s.batchDeleteItemsHandler))).Methods(http.MethodPost)
+
+	// Imports run in the background and are polled by ID
+	apiV1.Handle("/imports", writers(http.HandlerFunc(s.createImportHandler))).Methods(http.MethodPost)
+	apiV1.Handle("/imports/{id:[0-9]+}", readers(http.HandlerFunc(s.getImportHandler))).Methods(http.MethodGet)
+
+	// Attribute schemas are readable by everyone who can read items
+	apiV1.Handle("/attribute-schemas", readers(http.HandlerFunc(s.listAttributeSchemasHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/attribute-schemas/{namespace}", readers(http.HandlerFunc(s.attributeSchemaHandler))).Methods(http.MethodGet)
+	apiV1.Handle("/attribute-schemas/{namespace}", admins(http.HandlerFunc(s.attributeSchemaHandler))).Methods(http.MethodPut, http.MethodDelete)
+
+	// User management endpoints
+	users := apiV1.PathPrefix("/users").Subrouter()
+	users.Use(admins)
+	users.HandleFunc("", s.listUsersHandler).Methods(http.MethodGet)
+	users.HandleFunc("/{id:[0-9]+}", s.userHandler).Methods(http.MethodGet, http.MethodDelete)
+	users.HandleFunc("/{id:[0-9]+}/role", s.updateUserRoleHandler).Methods(http.MethodPut)
+
+	// API key management, only available to interactive sessions
+	apiKeys := apiV1.PathPrefix("/api-keys").Subrouter()
+	apiKeys.Use(auth.RequireSession)
+	apiKeys.HandleFunc("", s.listAPIKeysHandler).Methods(http.MethodGet)
+	apiKeys.HandleFunc("", s.createAPIKeyHandler).Methods(http.MethodPost)
+	apiKeys.HandleFunc("/{id:[0-9]+}", s.revokeAPIKeyHandler).Methods(http.MethodDelete)
+
+	// Handle 404 and 405 as problem+json
+	r.NotFoundHandler = unmatched(http.HandlerFunc(problem.NotFoundHandler))
+	r.MethodNotAllowedHandler = unmatched(http.HandlerFunc(problem.MethodNotAllowedHandler))
+
+	// The request ID wraps the whole router so unmatched requests get one too
+	return requestIDMiddleware(r), s.shutdownImports
+}
 
-	return r
+// unmatched applies the router-wide middleware to handlers mux runs without
+// middleware, so requests that match no route are measured, traced and logged
+func unmatched(h http.Handler) http.Handler {
+	return metrics.MetricsMiddleware(tracing.Middleware(logging.Middleware(h)))
 }
 
-// loggingMiddleware logs all HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
+// requestIDMiddleware accepts the caller's X-Request-ID or generates one, stores
+// it in the request context and echoes it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Create a custom map with LogKey type instead of string
-		fields := map[LogKey]interface{}{
-			"method": r.Method,
-			"path":   r.URL.Path,
-			"remote": r.RemoteAddr,
-			"agent":  r.UserAgent(),
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
 		}
 
-		// Convert to logrus.Fields
-		logFields := logrus.Fields{}
-		for k, v := range fields {
-			logFields[string(k)] = v
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), utils.RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts short printable ASCII IDs, so client-supplied values cannot inject into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
 		}
+	}
+	return true
+}
 
-		logrus.WithFields(logFields).Info("HTTP request")
-		next.ServeHTTP(w, r)
-	})
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
 }
 
-// notFoundHandler handles 404 errors
-func notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotFound)
-	if _, err := w.Write([]byte(`{"status":"error","error":"Resource not found"}`)); err != nil {
-		logrus.WithError(err).Error("Failed to write not found response")
+// allow combines a role check with an API key scope check
+func allow(scope models.Scope, roles ...models.Role) func(http.Handler) http.Handler {
+	requireRole := auth.RequireRole(roles...)
+	requireScope := auth.RequireScope(scope)
+	return func(next http.Handler) http.Handler {
+		return requireRole(requireScope(next))
 	}
 }
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/pkg/utils"
+)
+
+// searchItemsHandler handles GET /api/v1/items/search?q=, returning the
+// caller's items matching every word of q, most relevant first. It accepts the
+// pagination and filter parameters of the item list.
+func (s *server) searchItemsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	opts, err := parseItemListParams(r.URL.Query(), searchedItems)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.OwnerID = userID
+
+	results, hasMore, err := s.items.Search(r.Context(), opts)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to search items")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Build the paging metadata
+	pagination := models.Pagination{Limit: opts.Limit, HasMore: hasMore}
+	if hasMore {
+		pagination.NextCursor = cursorForResult(results[len(results)-1], opts)
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"items":      results,
+			"pagination": pagination,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode search response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"kubernetes-api/internal/auth"
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// trashHandler handles GET /api/v1/items/trash, listing the caller's deleted items
+func (s *server) trashHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.listItems(w, r, trashedItems)
+}
+
+// restoreItemHandler handles POST /api/v1/items/{id}/restore
+func (s *server) restoreItemHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	item, err := s.items.Restore(r.Context(), userID, itemID)
+	if err != nil {
+		writeItemError(w, r, err, "Failed to restore item")
+		return
+	}
+
+	// Return response
+	setItemETag(w, item)
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Item restored successfully",
+		Data: map[models.DataKey]interface{}{
+			"item": item,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// deleteTrashedItemHandler handles DELETE /api/v1/items/trash/{id}, removing
+// an item from the trash for good. Owners can delete their own items; admins
+// allowed to manage users can delete anyone's.
+func (s *server) deleteTrashedItemHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value(utils.UserIDKey).(int)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, "User ID not found in context")
+		return
+	}
+
+	itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	ownerID := userID
+	role, _ := r.Context().Value(utils.RoleKey).(models.Role)
+	if role == models.RoleAdmin && auth.HasScope(r.Context(), models.ScopeUsersManage) {
+		ownerID = 0
+	}
+
+	if err := s.items.DeletePermanently(r.Context(), ownerID, itemID); err != nil {
+		writeItemError(w, r, err, "Failed to delete item")
+		return
+	}
+	logging.FromContext(r.Context()).Infof("Item %d permanently deleted", itemID)
+
+	// Return response
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "Item permanently deleted",
+		Data:    map[models.DataKey]interface{}{},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode item response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// listUsersHandler handles GET /api/v1/users (admin only)
+func (s *server) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			problem.Error(w, r, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	afterID := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil || cursor.Sort != "id" {
+			problem.Error(w, r, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		afterID = cursor.ID
+	}
+
+	users, hasMore, err := s.users.List(r.Context(), afterID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to query users")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	pagination := models.Pagination{Limit: limit, HasMore: hasMore}
+	if hasMore {
+		pagination.NextCursor = encodeCursor(pageCursor{Sort: "id", ID: users[len(users)-1].ID})
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"users":      users,
+			"pagination": pagination,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode users response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// userHandler handles operations on a single user (admin only)
+func (s *server) userHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract user ID from URL
+	targetID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getUserHandler(w, r, targetID)
+	case http.MethodDelete:
+		s.deleteUserHandler(w, r, targetID)
+	default:
+		problem.Error(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// getUserHandler handles GET /api/v1/users/{id}
+func (s *server) getUserHandler(w http.ResponseWriter, r *http.Request, targetID int) {
+	user, err := s.users.GetByID(r.Context(), targetID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusNotFound, "User not found")
+		} else {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to query user")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status: "success",
+		Data: map[models.DataKey]interface{}{
+			"user": user,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode user response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// deleteUserHandler handles DELETE /api/v1/users/{id}
+func (s *server) deleteUserHandler(w http.ResponseWriter, r *http.Request, targetID int) {
+	// Admins cannot delete themselves, so there is always someone left to manage users
+	if callerID, _ := r.Context().Value(utils.UserIDKey).(int); callerID == targetID {
+		problem.Error(w, r, http.StatusBadRequest, "Cannot delete your own account")
+		return
+	}
+
+	if err := s.users.Delete(r.Context(), targetID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusNotFound, "User not found")
+		} else {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to delete user")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	// Return response
+	resp := models.ApiResponse{
+		Status:  "success",
+		Message: "User deleted successfully",
+		Data:    map[models.DataKey]interface{}{},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode user response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// updateUserRoleHandler handles PUT /api/v1/users/{id}/role
+func (s *server) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	targetID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.RoleRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if callerID, _ := r.Context().Value(utils.UserIDKey).(int); callerID == targetID {
+		problem.Error(w, r, http.StatusBadRequest, "Cannot change your own role")
+		return
+	}
+
+	if err := s.users.UpdateRole(r.Context(), targetID, req.Role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Error(w, r, http.StatusNotFound, "User not found")
+		} else {
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to update user role")
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	s.getUserHandler(w, r, targetID)
+}
@@ -0,0 +1,270 @@
+// Package attributes implements the free-form attributes of items. The
+// top-level keys of an item's attributes are namespaces, each optionally
+// described by a JSON Schema that writes are validated against, and items can
+// be filtered by the values at attribute paths.
+package attributes
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// MaxBytes caps the encoded size of an item's attributes
+const MaxBytes = 16 << 10
+
+// namePattern matches namespaces and the keys of filter paths
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// CheckName reports why name cannot be used as a namespace or path key
+func CheckName(name string) error {
+	if !namePattern.MatchString(name) {
+		return errors.New("must be 1-63 alphanumeric characters, '-' or '_'")
+	}
+	return nil
+}
+
+// Schema is the compiled JSON Schema of a namespace
+type Schema struct {
+	compiled *jsonschema.Schema
+}
+
+// Violation is one place where a value does not conform to a schema
+type Violation struct {
+	// Path locates the value below the namespace, dot-separated; empty for the
+	// namespace value itself
+	Path    string
+	Message string
+}
+
+// CompileSchema compiles the JSON Schema document of a namespace. Documents
+// default to draft 2020-12 and may not reference external resources.
+func CompileSchema(namespace string, doc []byte) (*Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external schema %s is not allowed", s)
+	}
+
+	url := "attributes:///" + namespace + ".json"
+	if err := compiler.AddResource(url, bytes.NewReader(doc)); err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{compiled: compiled}, nil
+}
+
+// Validate checks the value of a namespace, as decoded by encoding/json, and
+// returns the violations ordered by path
+func (s *Schema) Validate(value interface{}) []Violation {
+	err := s.compiled.Validate(value)
+	var verr *jsonschema.ValidationError
+	if err == nil || !errors.As(err, &verr) {
+		if err != nil {
+			return []Violation{{Message: err.Error()}}
+		}
+		return nil
+	}
+
+	// Report the innermost causes, which name the failing keyword
+	var violations []Violation
+	var collect func(*jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			path := strings.ReplaceAll(strings.TrimPrefix(e.InstanceLocation, "/"), "/", ".")
+			violations = append(violations, Violation{Path: path, Message: e.Message})
+			return
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(verr)
+
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations
+}
+
+// Operator is the comparison of a filter
+type Operator string
+
+const (
+	// Equals matches attributes with the value at the path
+	Equals Operator = "="
+	// NotEquals matches attributes without the path or with another value
+	NotEquals Operator = "!="
+	// Greater matches numbers or strings after the value
+	Greater Operator = ">"
+	// GreaterOrEqual matches numbers or strings not before the value
+	GreaterOrEqual Operator = ">="
+	// Less matches numbers or strings before the value
+	Less Operator = "<"
+	// LessOrEqual matches numbers or strings not after the value
+	LessOrEqual Operator = "<="
+)
+
+// Ordered reports whether the operator compares by order rather than equality
+func (o Operator) Ordered() bool {
+	return o != Equals && o != NotEquals
+}
+
+// operators lists the spellings of each operator, longest first so that
+// prefixes do not shadow them
+var operators = []struct {
+	token    string
+	operator Operator
+}{
+	{"==", Equals},
+	{"!=", NotEquals},
+	{">=", GreaterOrEqual},
+	{"<=", LessOrEqual},
+	{"=", Equals},
+	{">", Greater},
+	{"<", Less},
+}
+
+// Filter selects items by the value at an attribute path
+type Filter struct {
+	// Path holds the keys from the namespace down
+	Path     []string
+	Operator Operator
+	// Value is a string, float64, bool or nil
+	Value interface{}
+}
+
+// ParseFilter parses an expression such as "attributes.priority>=3" or
+// "attributes.ops.team=web". Values are read as JSON scalars, falling back to
+// plain strings, so "3" is a number and "\"3\"" a string.
+func ParseFilter(expr string) (Filter, error) {
+	rest, ok := strings.CutPrefix(expr, "attributes.")
+	if !ok {
+		return Filter{}, errors.New(`filter must start with "attributes."`)
+	}
+
+	end := strings.IndexAny(rest, "=!<>")
+	if end < 0 {
+		return Filter{}, fmt.Errorf("filter %q has no operator", expr)
+	}
+	var f Filter
+	for _, key := range strings.Split(rest[:end], ".") {
+		if err := CheckName(key); err != nil {
+			return Filter{}, fmt.Errorf("invalid path key %q: %w", key, err)
+		}
+		f.Path = append(f.Path, key)
+	}
+
+	raw := ""
+	for _, op := range operators {
+		if value, ok := strings.CutPrefix(rest[end:], op.token); ok {
+			f.Operator, raw = op.operator, value
+			break
+		}
+	}
+	if f.Operator == "" {
+		return Filter{}, fmt.Errorf("filter %q has an unknown operator", expr)
+	}
+
+	if err := json.Unmarshal([]byte(raw), &f.Value); err != nil {
+		f.Value = raw
+	}
+	switch f.Value.(type) {
+	case string, float64:
+	case bool, nil:
+		if f.Operator.Ordered() {
+			return Filter{}, fmt.Errorf("filter %q compares a value that is not a number or string", expr)
+		}
+	default:
+		return Filter{}, fmt.Errorf("filter %q has a value that is not a scalar", expr)
+	}
+	return f, nil
+}
+
+// Lookup returns the value at path
+func Lookup(attrs map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = attrs
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Matches reports whether attrs satisfy the filter
+func (f Filter) Matches(attrs map[string]interface{}) bool {
+	value, ok := Lookup(attrs, f.Path)
+	switch f.Operator {
+	case Equals:
+		return ok && reflect.DeepEqual(value, f.Value)
+	case NotEquals:
+		return !ok || !reflect.DeepEqual(value, f.Value)
+	}
+
+	var c int
+	switch want := f.Value.(type) {
+	case float64:
+		got, isNumber := value.(float64)
+		if !isNumber {
+			return false
+		}
+		c = cmp.Compare(got, want)
+	case string:
+		got, isString := value.(string)
+		if !isString {
+			return false
+		}
+		c = strings.Compare(got, want)
+	default:
+		return false
+	}
+
+	switch f.Operator {
+	case Greater:
+		return c > 0
+	case GreaterOrEqual:
+		return c >= 0
+	case Less:
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+// Containment returns the JSON object holding value at path, for matching
+// Equals filters by containment
+func (f Filter) Containment() ([]byte, error) {
+	var doc interface{} = f.Value
+	for i := len(f.Path) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{f.Path[i]: doc}
+	}
+	return json.Marshal(doc)
+}
+
+// JSONPath returns an SQL/JSON path matching items whose value at the path
+// compares to $v, for ordered filters
+func (f Filter) JSONPath() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range f.Path {
+		// Keys are checked by CheckName, so they need no escaping
+		b.WriteString(`."` + key + `"`)
+	}
+	b.WriteString(" ? (@ " + string(f.Operator) + " $v)")
+	return b.String()
+}
@@ -0,0 +1,235 @@
+package attributes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Filter
+	}{
+		{"attributes.ops.priority>=3", Filter{Path: []string{"ops", "priority"}, Operator: GreaterOrEqual, Value: 3.0}},
+		{"attributes.ops.priority<=3", Filter{Path: []string{"ops", "priority"}, Operator: LessOrEqual, Value: 3.0}},
+		{"attributes.ops.priority>2.5", Filter{Path: []string{"ops", "priority"}, Operator: Greater, Value: 2.5}},
+		{"attributes.ops.priority<1e3", Filter{Path: []string{"ops", "priority"}, Operator: Less, Value: 1000.0}},
+		{"attributes.ops.team=web", Filter{Path: []string{"ops", "team"}, Operator: Equals, Value: "web"}},
+		{"attributes.ops.team==web", Filter{Path: []string{"ops", "team"}, Operator: Equals, Value: "web"}},
+		{"attributes.ops.team!=web", Filter{Path: []string{"ops", "team"}, Operator: NotEquals, Value: "web"}},
+		{`attributes.ops.code="3"`, Filter{Path: []string{"ops", "code"}, Operator: Equals, Value: "3"}},
+		{"attributes.ops.code=3", Filter{Path: []string{"ops", "code"}, Operator: Equals, Value: 3.0}},
+		{"attributes.ops.active=true", Filter{Path: []string{"ops", "active"}, Operator: Equals, Value: true}},
+		{"attributes.ops.owner=null", Filter{Path: []string{"ops", "owner"}, Operator: Equals, Value: nil}},
+		{"attributes.ops.owner!=null", Filter{Path: []string{"ops", "owner"}, Operator: NotEquals, Value: nil}},
+		{"attributes.ops.team=", Filter{Path: []string{"ops", "team"}, Operator: Equals, Value: ""}},
+		{"attributes.ops.team=web team", Filter{Path: []string{"ops", "team"}, Operator: Equals, Value: "web team"}},
+		{"attributes.ops.name>=m", Filter{Path: []string{"ops", "name"}, Operator: GreaterOrEqual, Value: "m"}},
+		{"attributes.ops.expr==>3", Filter{Path: []string{"ops", "expr"}, Operator: Equals, Value: ">3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) returned error: %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilter(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{"missing prefix", "ops.team=web", `must start with "attributes."`},
+		{"no operator", "attributes.ops.team", "has no operator"},
+		{"empty path", "attributes.=web", "invalid path key"},
+		{"empty path key", "attributes.ops..team=web", "invalid path key"},
+		{"bad path key", "attributes.ops.te am=web", "invalid path key"},
+		{"bare bang", "attributes.ops.team!web", "unknown operator"},
+		{"ordered bool", "attributes.ops.active>true", "not a number or string"},
+		{"ordered null", "attributes.ops.owner<=null", "not a number or string"},
+		{"array value", "attributes.ops.tags=[1,2]", "not a scalar"},
+		{"object value", `attributes.ops.meta={"a":1}`, "not a scalar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFilter(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseFilter(%q) succeeded, want error containing %q", tt.expr, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseFilter(%q) error = %q, want it to contain %q", tt.expr, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	attrs := map[string]interface{}{
+		"ops": map[string]interface{}{
+			"priority": 3.0,
+			"team":     "web",
+			"code":     "3",
+			"active":   true,
+			"owner":    nil,
+			"tags":     []interface{}{"a"},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"attributes.ops.priority=3", true},
+		{"attributes.ops.priority=4", false},
+		{`attributes.ops.priority="3"`, false},
+		{`attributes.ops.code="3"`, true},
+		{"attributes.ops.code=3", false},
+		{"attributes.ops.team=web", true},
+		{"attributes.ops.team!=web", false},
+		{"attributes.ops.team!=db", true},
+		{"attributes.ops.missing!=web", true},
+		{"attributes.ops.missing=web", false},
+		{"attributes.ops.active=true", true},
+		{"attributes.ops.active!=false", true},
+		{"attributes.ops.owner=null", true},
+		{"attributes.ops.missing=null", false},
+		{"attributes.ops.priority>2", true},
+		{"attributes.ops.priority>3", false},
+		{"attributes.ops.priority>=3", true},
+		{"attributes.ops.priority<3", false},
+		{"attributes.ops.priority<=3", true},
+		{"attributes.ops.team>a", true},
+		{"attributes.ops.team<a", false},
+		{"attributes.ops.code>2", false},
+		{"attributes.ops.code<4", false},
+		{"attributes.ops.priority>a", false},
+		{"attributes.ops.active>0", false},
+		{"attributes.ops.owner<1", false},
+		{"attributes.ops.missing>0", false},
+		{"attributes.ops.tags>0", false},
+		{"attributes.ops.priority.deeper=3", false},
+		{"attributes.ops.priority.deeper!=3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) returned error: %v", tt.expr, err)
+			}
+			if got := f.Matches(attrs); got != tt.want {
+				t.Errorf("%q matches = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatchesOrderedNonScalar(t *testing.T) {
+	// ParseFilter refuses these, but filters built directly never match
+	attrs := map[string]interface{}{"ops": map[string]interface{}{"active": true, "owner": nil}}
+	for _, f := range []Filter{
+		{Path: []string{"ops", "active"}, Operator: Greater, Value: false},
+		{Path: []string{"ops", "active"}, Operator: LessOrEqual, Value: true},
+		{Path: []string{"ops", "owner"}, Operator: GreaterOrEqual, Value: nil},
+	} {
+		if f.Matches(attrs) {
+			t.Errorf("%#v matches, want no match", f)
+		}
+	}
+}
+
+func TestFilterQueries(t *testing.T) {
+	tests := []struct {
+		expr        string
+		containment string
+		jsonPath    string
+	}{
+		{"attributes.ops.team=web", `{"ops":{"team":"web"}}`, `$."ops"."team" ? (@ = $v)`},
+		{"attributes.ops.priority>=3", `{"ops":{"priority":3}}`, `$."ops"."priority" ? (@ >= $v)`},
+		{"attributes.ops.owner=null", `{"ops":{"owner":null}}`, `$."ops"."owner" ? (@ = $v)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) returned error: %v", tt.expr, err)
+			}
+			doc, err := f.Containment()
+			if err != nil {
+				t.Fatalf("Containment() returned error: %v", err)
+			}
+			if string(doc) != tt.containment {
+				t.Errorf("Containment() = %s, want %s", doc, tt.containment)
+			}
+			if got := f.JSONPath(); got != tt.jsonPath {
+				t.Errorf("JSONPath() = %s, want %s", got, tt.jsonPath)
+			}
+		})
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"ops", true},
+		{"Ops_2-x", true},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+		{"", false},
+		{"a.b", false},
+		{"a b", false},
+		{"ä", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckName(tt.name); (err == nil) != tt.valid {
+				t.Errorf("CheckName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := CompileSchema("ops", []byte(`{
+		"type": "object",
+		"properties": {
+			"priority": {"type": "integer", "minimum": 1},
+			"team": {"type": "string"}
+		},
+		"required": ["team"]
+	}`))
+	if err != nil {
+		t.Fatalf("CompileSchema returned error: %v", err)
+	}
+
+	if violations := schema.Validate(map[string]interface{}{"team": "web", "priority": 2.0}); len(violations) != 0 {
+		t.Errorf("valid value has violations %v", violations)
+	}
+
+	violations := schema.Validate(map[string]interface{}{"priority": 0.0})
+	paths := make([]string, len(violations))
+	for i, v := range violations {
+		paths[i] = v.Path
+	}
+	if want := []string{"", "priority"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("violation paths = %q, want %q", paths, want)
+	}
+
+	if _, err := CompileSchema("ops", []byte(`{"$ref": "https://example.com/schema.json"}`)); err == nil {
+		t.Error("CompileSchema accepted an external reference")
+	}
+}
@@ -3,7 +3,10 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,7 +16,10 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"kubernetes-api/internal/logging"
 	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/internal/repository"
 	"kubernetes-api/pkg/utils"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -21,33 +27,99 @@ import (
 )
 
 var (
-	jwtKey []byte
+	// keys signs and verifies access tokens
+	keys *KeySet
+
+	// accessTokenTTL is the lifetime of issued JWT access tokens
+	accessTokenTTL = 15 * time.Minute
+	// refreshTokenTTL is the lifetime of each issued refresh token
+	refreshTokenTTL = 30 * 24 * time.Hour
 )
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int         `json:"user_id"`
+	Username  string      `json:"username"`
+	Role      models.Role `json:"role"`
+	SessionID string      `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// SessionChecker reports whether a login session has been revoked
+type SessionChecker interface {
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
 // InitAuth initializes the authentication module
 func InitAuth() error {
-	// Use environment variable or generate a random key
-	keyStr := os.Getenv("JWT_SECRET")
-	if keyStr == "" {
-		key := make([]byte, 32)
-		_, err := rand.Read(key)
-		if err != nil {
+	var err error
+
+	// Load signing keys from the mounted directory, or generate an ephemeral key when explicitly allowed
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		if utils.GetEnv("JWT_EPHEMERAL_KEY", "false") != "true" {
+			return errors.New("JWT_KEYS_DIR not set; set JWT_EPHEMERAL_KEY=true to use a per-process key for local development")
+		}
+		if keys, err = NewEphemeralKeySet(); err != nil {
 			return fmt.Errorf("failed to generate JWT key: %w", err)
 		}
-		keyStr = base64.StdEncoding.EncodeToString(key)
-		logrus.Warn("JWT_SECRET not set, generated random key. This is not suitable for production.")
+		logrus.Warn("JWT_KEYS_DIR not set, generated ephemeral signing key. Tokens will not verify across replicas.")
+	} else {
+		activeKid := os.Getenv("JWT_ACTIVE_KID")
+		if keys, err = LoadKeySet(keysDir, activeKid); err != nil {
+			return fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+		logrus.Infof("Loaded %d JWT signing key(s) from %s", keys.Len(), keysDir)
+
+		// Pick up rotated keys from the mounted Secret without a restart
+		interval, err := time.ParseDuration(utils.GetEnv("JWT_KEYS_RELOAD_INTERVAL", "1m"))
+		if err != nil {
+			return fmt.Errorf("invalid JWT_KEYS_RELOAD_INTERVAL: %w", err)
+		}
+		if interval > 0 {
+			go reloadKeys(keysDir, activeKid, interval)
+		}
+	}
+
+	// Token lifetimes
+	if accessTokenTTL, err = time.ParseDuration(utils.GetEnv("ACCESS_TOKEN_TTL", "15m")); err != nil {
+		return fmt.Errorf("invalid ACCESS_TOKEN_TTL: %w", err)
+	}
+	if refreshTokenTTL, err = time.ParseDuration(utils.GetEnv("REFRESH_TOKEN_TTL", "720h")); err != nil {
+		return fmt.Errorf("invalid REFRESH_TOKEN_TTL: %w", err)
 	}
-	jwtKey = []byte(keyStr)
+
 	return nil
 }
 
+// CheckKeys returns an error unless a signing key is loaded, for use as a health check
+func CheckKeys(ctx context.Context) error {
+	if keys == nil {
+		return errors.New("signing keys not loaded")
+	}
+	return keys.checkActive()
+}
+
+// reloadKeys periodically reloads the key directory, keeping the current keys on failure.
+// New keys are only loaded for verification: the signing key stays the one chosen at
+// startup, since other replicas may not have loaded a new key yet.
+func reloadKeys(dir, activeKid string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if activeKid == "" {
+		activeKid = keys.activeKid()
+	}
+	for range ticker.C {
+		reloaded, err := LoadKeySet(dir, activeKid)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to reload JWT keys, keeping current keys")
+			continue
+		}
+		keys.Replace(reloaded)
+	}
+}
+
 // HashPassword creates a bcrypt hash of the password
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -60,13 +132,15 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateJWT creates a new JWT token for a user
-func GenerateJWT(user models.User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateJWT creates a new short-lived JWT access token for a user's session
+func GenerateJWT(user models.User, sessionID string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(accessTokenTTL)
 
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -76,25 +150,47 @@ func GenerateJWT(user models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// GenerateRefreshToken creates a random opaque refresh token, returning the
+// token, the hash to store and its expiry
+func GenerateRefreshToken() (string, string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), time.Now().Add(refreshTokenTTL), nil
+}
 
-	return tokenString, nil
+// HashToken returns the hex-encoded SHA-256 hash under which an opaque token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSessionID generates a random session identifier
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // ValidateJWT validates a JWT token
 func ValidateJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+	)
 
 	if err != nil {
 		return nil, err
@@ -107,34 +203,103 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// ExtractTokenFromRequest extracts JWT token from Authorization header
+// ExtractTokenFromRequest extracts JWT token from the Authorization: Bearer header
 func ExtractTokenFromRequest(r *http.Request) string {
-	bearerToken := r.Header.Get("Authorization")
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		return strings.Split(bearerToken, " ")[1]
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return token
 	}
 	return ""
 }
 
-// AuthMiddleware is a middleware to authenticate requests
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := ExtractTokenFromRequest(r)
-		if tokenString == "" {
-			http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-			return
-		}
+// JWKSHandler serves the public signing keys at /.well-known/jwks.json so other
+// services can verify tokens issued by this API
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(keys.JWKS()); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode JWKS response")
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
 
-		claims, err := ValidateJWT(tokenString)
-		if err != nil {
-			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-			return
-		}
+// AuthMiddleware returns a middleware that authenticates requests with either a
+// Bearer JWT, rejecting tokens whose session has been revoked, or an API key
+func AuthMiddleware(sessions SessionChecker, apiKeys APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := ExtractAPIKeyFromRequest(r); apiKey != "" {
+				record, err := authenticateAPIKey(r.Context(), apiKeys, apiKey)
+				if err != nil {
+					if !errors.Is(err, errInvalidAPIKey) && !errors.Is(err, repository.ErrNotFound) {
+						logging.FromContext(r.Context()).WithError(err).Error("Failed to look up API key")
+						problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+						return
+					}
+					problem.Error(w, r, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+
+				// API keys act as their owner with the owner's current role
+				ctx := r.Context()
+				ctx = context.WithValue(ctx, utils.UserIDKey, record.Owner.ID)
+				ctx = context.WithValue(ctx, utils.UsernameKey, record.Owner.Username)
+				ctx = context.WithValue(ctx, utils.RoleKey, record.Owner.Role)
+				ctx = context.WithValue(ctx, utils.ScopesKey, record.Scopes)
+				ctx = context.WithValue(ctx, utils.APIKeyIDKey, record.ID)
+				ctx = logging.SetUser(ctx, record.Owner.ID)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			tokenString := ExtractTokenFromRequest(r)
+			if tokenString == "" {
+				problem.Error(w, r, http.StatusUnauthorized, "No token provided")
+				return
+			}
+
+			claims, err := ValidateJWT(tokenString)
+			if err != nil || claims.SessionID == "" {
+				problem.Error(w, r, http.StatusUnauthorized, "Invalid token")
+				return
+			}
 
-		// Add claims to request context using custom keys
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, utils.UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, utils.UsernameKey, claims.Username)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			revoked, err := sessions.IsRevoked(r.Context(), claims.SessionID)
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).Error("Failed to check session")
+				problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if revoked {
+				problem.Error(w, r, http.StatusUnauthorized, "Session revoked")
+				return
+			}
+
+			// Add claims to request context using custom keys
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, utils.UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, utils.UsernameKey, claims.Username)
+			ctx = context.WithValue(ctx, utils.RoleKey, claims.Role)
+			ctx = context.WithValue(ctx, utils.SessionIDKey, claims.SessionID)
+			ctx = logging.SetUser(ctx, claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole returns a middleware that only lets through callers holding one
+// of the given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(utils.RoleKey).(models.Role)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			problem.Error(w, r, http.StatusForbidden, "Insufficient role")
+		})
+	}
 }
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one key of the key set, identified by its kid
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	// private is nil for verify-only keys that are being rotated out
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet holds the keys used to sign and verify tokens. One key is active for
+// signing; every key in the set keeps verifying until its file is removed.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]*signingKey
+	active string
+}
+
+// JWK is a JSON Web Key as served from the JWKS endpoint
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set document
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet reads every *.pem file in dir. The file name without extension is
+// the kid. Files may hold an RSA or Ed25519 private key (sign and verify) or a
+// public key (verify only). activeKid selects the signing key; it may only be
+// empty when dir holds a single private key. Picking one of several would let
+// a replica sign with a key other replicas have not loaded yet.
+func LoadKeySet(dir, activeKid string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{keys: map[string]*signingKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := parseKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+		}
+		set.keys[kid] = key
+	}
+
+	if activeKid == "" {
+		kids := make([]string, 0, len(set.keys))
+		for kid, key := range set.keys {
+			if key.private != nil {
+				kids = append(kids, kid)
+			}
+		}
+		if len(kids) == 0 {
+			return nil, fmt.Errorf("no private signing keys found in %s", dir)
+		}
+		if len(kids) > 1 {
+			sort.Strings(kids)
+			return nil, fmt.Errorf("private keys %s found in %s; set JWT_ACTIVE_KID to the one to sign with", strings.Join(kids, ", "), dir)
+		}
+		activeKid = kids[0]
+	}
+
+	key, ok := set.keys[activeKid]
+	if !ok || key.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key in %s", activeKid, dir)
+	}
+	set.active = activeKid
+
+	return set, nil
+}
+
+// NewEphemeralKeySet generates a single in-memory Ed25519 key
+func NewEphemeralKeySet() (*KeySet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kid := "ephemeral"
+	return &KeySet{
+		keys: map[string]*signingKey{
+			kid: {kid: kid, method: jwt.SigningMethodEdDSA, private: private, public: public},
+		},
+		active: kid,
+	}, nil
+}
+
+// parseKey decodes a PEM encoded private or public key
+func parseKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	return key, nil
+}
+
+// Sign signs the claims with the active key, setting the kid header
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	key := s.keys[s.active]
+	s.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// Replace swaps in the keys of another set, used when keys are reloaded
+func (s *KeySet) Replace(other *KeySet) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = other.keys
+	s.active = other.active
+}
+
+// activeKid returns the kid of the signing key
+func (s *KeySet) activeKid() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// checkActive returns an error unless the active key can sign
+func (s *KeySet) checkActive() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[s.active]
+	if !ok || key.private == nil {
+		return fmt.Errorf("active signing key %q not loaded", s.active)
+	}
+	return nil
+}
+
+// Len returns the number of loaded keys
+func (s *KeySet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// JWKS returns the public half of every key in the set
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	doc := JWKS{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := s.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+
+	return doc
+}
@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"kubernetes-api/internal/migrations"
+
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
@@ -13,8 +17,27 @@ import (
 // DB is a global database connection pool
 var DB *sql.DB
 
-// InitDB initializes database connection
+// InitDB initializes database connection and applies pending migrations
 func InitDB() error {
+	if err := Connect(); err != nil {
+		return err
+	}
+
+	// Apply schema migrations unless they are run as a separate step
+	if getEnv("DB_AUTO_MIGRATE", "true") == "true" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := migrations.Up(ctx, DB); err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+	}
+
+	logrus.Info("Database connection established successfully")
+	return nil
+}
+
+// Connect opens the database connection pool without touching the schema
+func Connect() error {
 	var err error
 
 	host := getEnv("DB_HOST", "localhost")
@@ -50,37 +73,15 @@ func InitDB() error {
 	DB.SetMaxIdleConns(10)
 	DB.SetConnMaxLifetime(time.Minute * 5)
 
-	// Create schema if not exists
-	if err := createSchema(); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
-	}
-
-	logrus.Info("Database connection established successfully")
 	return nil
 }
 
-// createSchema sets up the initial database schema if not exists
-func createSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS items (
-		id SERIAL PRIMARY KEY,
-		name TEXT NOT NULL,
-		description TEXT,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-	`
-
-	_, err := DB.Exec(schema)
-	return err
+// Ping checks that the database is reachable, for use as a health check
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+	return DB.PingContext(ctx)
 }
 
 // CloseDB gracefully closes database connection
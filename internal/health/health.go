@@ -0,0 +1,203 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startTime approximates when the process started, for reporting uptime
+var startTime = time.Now()
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// Probe is one of the Kubernetes probe kinds a check can take part in
+type Probe string
+
+const (
+	// Liveness failures make the kubelet restart the container
+	Liveness Probe = "livez"
+	// Readiness failures take the pod out of the Service endpoints
+	Readiness Probe = "readyz"
+	// Startup holds off the other probes until initialization has finished
+	Startup Probe = "startupz"
+)
+
+// Check reports a dependency as unhealthy by returning an error
+type Check func(ctx context.Context) error
+
+// namedCheck is a registered check
+type namedCheck struct {
+	name   string
+	check  Check
+	probes []Probe
+}
+
+// Registry holds the checks behind the probe endpoints
+type Registry struct {
+	mu           sync.RWMutex
+	checks       []namedCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates an empty Registry. Each check is given timeout to complete.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check to the given probes
+func (reg *Registry) Register(name string, check Check, probes ...Probe) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.checks = append(reg.checks, namedCheck{name: name, check: check, probes: probes})
+}
+
+// SetShuttingDown makes readiness fail from now on, so the pod is removed from
+// the Service endpoints while in-flight requests drain
+func (reg *Registry) SetShuttingDown() {
+	reg.shuttingDown.Store(true)
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the response body of a probe endpoint
+type Report struct {
+	Status string   `json:"status"`
+	Uptime string   `json:"uptime"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Run executes every check registered for the probe concurrently, skipping the
+// names in exclude, and reports whether all of them passed
+func (reg *Registry) Run(ctx context.Context, probe Probe, exclude map[string]bool) ([]Result, bool) {
+	reg.mu.RLock()
+	var checks []namedCheck
+	for _, c := range reg.checks {
+		if !exclude[c.name] && hasProbe(c.probes, probe) {
+			checks = append(checks, c)
+		}
+	}
+	reg.mu.RUnlock()
+
+	if probe == Readiness && !exclude["shutdown"] {
+		checks = append(checks, namedCheck{name: "shutdown", check: reg.checkShutdown})
+	}
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = reg.runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	ok := true
+	for _, result := range results {
+		if result.Status != "ok" {
+			ok = false
+		}
+	}
+	return results, ok
+}
+
+// runCheck runs a single check with the registry timeout
+func (reg *Registry) runCheck(ctx context.Context, c namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: c.name, Status: "ok", Duration: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkShutdown fails once shutdown has begun
+func (reg *Registry) checkShutdown(ctx context.Context) error {
+	if reg.shuttingDown.Load() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
+
+// Handler serves a probe endpoint. It responds 200 when every check passes and
+// 503 otherwise. Per-check results are included with ?verbose, and checks can
+// be skipped with ?exclude=name (repeatable).
+func (reg *Registry) Handler(probe Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		exclude := map[string]bool{}
+		for _, name := range query["exclude"] {
+			exclude[strings.TrimSpace(name)] = true
+		}
+
+		results, ok := reg.Run(r.Context(), probe, exclude)
+
+		report := Report{Status: "ok", Uptime: Uptime().Round(time.Second).String()}
+		status := http.StatusOK
+		if !ok {
+			report.Status = "failed"
+			status = http.StatusServiceUnavailable
+			for _, result := range results {
+				if result.Status != "ok" {
+					logrus.WithField("check", result.Name).Warnf("%s check failed: %s", probe, result.Error)
+				}
+			}
+		}
+		if _, verbose := query["verbose"]; verbose || !ok {
+			report.Checks = results
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logrus.WithError(err).Errorf("Failed to encode %s response", probe)
+		}
+	}
+}
+
+// hasProbe reports whether probe is in probes
+func hasProbe(probes []Probe, probe Probe) bool {
+	for _, p := range probes {
+		if p == probe {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+// Package labels implements Kubernetes-style key/value labels on items and the
+// label selectors used to filter them, following the kubectl syntax.
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxLabels is the number of labels an item may carry
+	MaxLabels = 64
+	// maxNameLength caps label names and values
+	maxNameLength = 63
+	// maxPrefixLength caps the DNS subdomain prefix of a label key
+	maxPrefixLength = 253
+)
+
+var (
+	// namePattern matches label names and non-empty values
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	// prefixPattern matches the DNS subdomain prefix of a label key
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// CheckKey reports why key is not a valid label key: an optional DNS subdomain
+// prefix and a slash, followed by a name of up to 63 characters
+func CheckKey(key string) error {
+	name := key
+	if prefix, rest, ok := strings.Cut(key, "/"); ok {
+		if len(prefix) > maxPrefixLength || !prefixPattern.MatchString(prefix) {
+			return errors.New("key prefix must be a lowercase DNS subdomain")
+		}
+		name = rest
+	}
+	if name == "" || len(name) > maxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("key name must be 1-%d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", maxNameLength)
+	}
+	return nil
+}
+
+// CheckValue reports why value is not a valid label value: empty, or up to 63
+// characters following the rules of key names
+func CheckValue(value string) error {
+	if value != "" && (len(value) > maxNameLength || !namePattern.MatchString(value)) {
+		return fmt.Errorf("value must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", maxNameLength)
+	}
+	return nil
+}
+
+// Operator is the comparison of a selector requirement
+type Operator string
+
+const (
+	// Equals matches labels with the key set to the value
+	Equals Operator = "="
+	// NotEquals matches labels without the key or with another value
+	NotEquals Operator = "!="
+	// In matches labels with the key set to one of the values
+	In Operator = "in"
+	// NotIn matches labels without the key or with a value not listed
+	NotIn Operator = "notin"
+	// Exists matches labels with the key
+	Exists Operator = "exists"
+	// DoesNotExist matches labels without the key
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is one comma-separated expression of a selector
+type Requirement struct {
+	Key      string
+	Operator Operator
+	// Values holds the value for Equals and NotEquals, and the set for In and NotIn
+	Values []string
+}
+
+// Matches reports whether labels satisfy the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return ok && contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !ok || !contains(r.Values, value)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector is a list of requirements that must all match. The empty Selector
+// matches everything.
+type Selector []Requirement
+
+// Matches reports whether labels satisfy every requirement
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse parses a selector such as "env=prod,tier!=db,team in (a,b),!legacy".
+// It supports =, ==, !=, in, notin, bare keys for existence and ! for absence.
+func Parse(s string) (Selector, error) {
+	p := &parser{input: s}
+	var selector Selector
+	for {
+		p.skipSpaces()
+		if p.done() {
+			if len(selector) > 0 {
+				return nil, errors.New("selector ends with a comma")
+			}
+			return selector, nil
+		}
+
+		req, err := p.requirement()
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, req)
+
+		p.skipSpaces()
+		if p.done() {
+			return selector, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected ',' at position %d", p.pos)
+		}
+	}
+}
+
+// parser is the state of Parse
+type parser struct {
+	input string
+	pos   int
+}
+
+// done reports whether the whole input has been read
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+// skipSpaces advances past whitespace
+func (p *parser) skipSpaces() {
+	for !p.done() && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// consume advances past token if the input continues with it
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// word reads up to the next space or operator character
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(" \t=!(),", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// requirement reads one requirement
+func (p *parser) requirement() (Requirement, error) {
+	if p.consume("!") {
+		p.skipSpaces()
+		key := p.word()
+		if err := CheckKey(key); err != nil {
+			return Requirement{}, fmt.Errorf("invalid key %q: %w", key, err)
+		}
+		return Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+
+	key := p.word()
+	if err := CheckKey(key); err != nil {
+		return Requirement{}, fmt.Errorf("invalid key %q: %w", key, err)
+	}
+	p.skipSpaces()
+
+	var op Operator
+	switch {
+	case p.done() || strings.HasPrefix(p.input[p.pos:], ","):
+		return Requirement{Key: key, Operator: Exists}, nil
+	case p.consume("!="):
+		op = NotEquals
+	case p.consume("=="), p.consume("="):
+		op = Equals
+	default:
+		switch word := p.word(); word {
+		case "in":
+			op = In
+		case "notin":
+			op = NotIn
+		default:
+			return Requirement{}, fmt.Errorf("unknown operator %q after key %q", word, key)
+		}
+	}
+
+	if op == Equals || op == NotEquals {
+		p.skipSpaces()
+		value := p.word()
+		if err := CheckValue(value); err != nil {
+			return Requirement{}, fmt.Errorf("invalid value %q for key %q: %w", value, key, err)
+		}
+		return Requirement{Key: key, Operator: op, Values: []string{value}}, nil
+	}
+
+	values, err := p.set()
+	if err != nil {
+		return Requirement{}, fmt.Errorf("invalid set for key %q: %w", key, err)
+	}
+	return Requirement{Key: key, Operator: op, Values: values}, nil
+}
+
+// set reads a parenthesized, comma-separated list of values
+func (p *parser) set() ([]string, error) {
+	p.skipSpaces()
+	if !p.consume("(") {
+		return nil, errors.New("expected '('")
+	}
+
+	var values []string
+	for {
+		p.skipSpaces()
+		value := p.word()
+		if err := CheckValue(value); err != nil {
+			return nil, fmt.Errorf("invalid value %q: %w", value, err)
+		}
+		values = append(values, value)
+
+		p.skipSpaces()
+		switch {
+		case p.consume(","):
+		case p.consume(")"):
+			return values, nil
+		default:
+			return nil, errors.New("expected ',' or ')'")
+		}
+	}
+}
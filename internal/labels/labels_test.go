@@ -0,0 +1,155 @@
+package labels
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Selector
+	}{
+		{"empty", "", nil},
+		{"blank", "  ", nil},
+		{"equals", "env=prod", Selector{{Key: "env", Operator: Equals, Values: []string{"prod"}}}},
+		{"double equals", "env==prod", Selector{{Key: "env", Operator: Equals, Values: []string{"prod"}}}},
+		{"not equals", "env!=prod", Selector{{Key: "env", Operator: NotEquals, Values: []string{"prod"}}}},
+		{"empty value", "env=", Selector{{Key: "env", Operator: Equals, Values: []string{""}}}},
+		{"exists", "env", Selector{{Key: "env", Operator: Exists}}},
+		{"does not exist", "!env", Selector{{Key: "env", Operator: DoesNotExist}}},
+		{"does not exist with space", "! env", Selector{{Key: "env", Operator: DoesNotExist}}},
+		{"in", "tier in (web, api)", Selector{{Key: "tier", Operator: In, Values: []string{"web", "api"}}}},
+		{"notin", "tier notin (db)", Selector{{Key: "tier", Operator: NotIn, Values: []string{"db"}}}},
+		{"prefixed key", "example.com/team=data", Selector{{Key: "example.com/team", Operator: Equals, Values: []string{"data"}}}},
+		{
+			"several",
+			" env = prod , tier in (web,api),!legacy, team ",
+			Selector{
+				{Key: "env", Operator: Equals, Values: []string{"prod"}},
+				{Key: "tier", Operator: In, Values: []string{"web", "api"}},
+				{Key: "legacy", Operator: DoesNotExist},
+				{Key: "team", Operator: Exists},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"trailing comma", "env=prod,", "ends with a comma"},
+		{"leading comma", ",env=prod", "invalid key"},
+		{"double comma", "env=prod,,tier", "invalid key"},
+		{"missing key", "=prod", "invalid key"},
+		{"bad key", "-env=prod", "invalid key"},
+		{"bad prefix", "Example.com/team=data", "invalid key"},
+		{"bad value", "env=-prod", "invalid value"},
+		{"value too long", "env=" + strings.Repeat("a", 64), "invalid value"},
+		{"unknown operator", "env like prod", "unknown operator"},
+		{"value after absence", "!env=prod", "expected ','"},
+		{"second value", "env=prod=dev", "expected ','"},
+		{"set without parentheses", "tier in web", "expected '('"},
+		{"unclosed set", "tier in (web", "expected ',' or ')'"},
+		{"bad set value", "tier in (web, -api)", "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want error containing %q", tt.input, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse(%q) error = %q, want it to contain %q", tt.input, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "web", "empty": ""}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"env!=prod", false},
+		{"team!=data", true},
+		{"empty=", true},
+		{"empty", true},
+		{"team", false},
+		{"!team", true},
+		{"!env", false},
+		{"tier in (web,api)", true},
+		{"tier in (db)", false},
+		{"team in (data)", false},
+		{"tier notin (db)", true},
+		{"tier notin (web)", false},
+		{"team notin (data)", true},
+		{"env=prod,tier=web", true},
+		{"env=prod,tier=db", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.selector, err)
+			}
+			if got := selector.Matches(labels); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.selector, labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"env", true},
+		{"app.kubernetes.io/name", true},
+		{"a", true},
+		{"a_b-c.d", true},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+		{"", false},
+		{"-env", false},
+		{"env-", false},
+		{"example.com/", false},
+		{"/env", false},
+		{"Example.com/env", false},
+		{"a/b/c", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := CheckKey(tt.key); (err == nil) != tt.valid {
+				t.Errorf("CheckKey(%q) = %v, want valid %v", tt.key, err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// contextKey is the type of the keys this package stores in a context
+type contextKey int
+
+const (
+	entryKey contextKey = iota
+	requestKey
+)
+
+// requestState collects what inner middleware learns about a request, such as
+// the authenticated user, for the access log line written once it completes
+type requestState struct {
+	userID int
+}
+
+// FromContext returns the request-scoped logger, carrying the request ID and
+// any fields added with WithFields. Outside a request it returns the standard
+// logger. Entries are bound to ctx so trace IDs are added by the tracing hook.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey).(*logrus.Entry); ok {
+		return entry.WithContext(ctx)
+	}
+	return logrus.WithContext(ctx)
+}
+
+// NewContext returns a copy of ctx carrying entry as its logger
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey, entry)
+}
+
+// WithFields returns a copy of ctx whose logger carries the given fields
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// SetUser records the authenticated user for the access log and adds it to the
+// logger of the returned context
+func SetUser(ctx context.Context, userID int) context.Context {
+	if state, ok := ctx.Value(requestKey).(*requestState); ok {
+		state.userID = userID
+	}
+	return WithFields(ctx, logrus.Fields{"user_id": userID})
+}
+
+// Middleware installs the request-scoped logger and writes one access log line
+// after the handler has finished. Like metrics.MetricsMiddleware it also wraps
+// the router's NotFound and MethodNotAllowed handlers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		fields := logrus.Fields{"method": r.Method, "route": route}
+		if requestID, ok := r.Context().Value(utils.RequestIDKey).(string); ok {
+			fields["request_id"] = requestID
+		}
+
+		state := &requestState{}
+		ctx := context.WithValue(r.Context(), requestKey, state)
+		ctx = NewContext(ctx, logrus.WithFields(fields))
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// Requests whose handler panics, such as aborted streaming responses,
+		// are logged before the panic reaches the server
+		defer func() {
+			aborted := recover()
+
+			access := FromContext(ctx).WithFields(logrus.Fields{
+				"path":        r.URL.Path,
+				"status":      recorder.status,
+				"bytes":       recorder.bytes,
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"remote":      r.RemoteAddr,
+				"agent":       r.UserAgent(),
+			})
+			if state.userID != 0 {
+				access = access.WithField("user_id", state.userID)
+			}
+			if aborted != nil {
+				access.WithField("aborted", true).Error("HTTP request aborted")
+				panic(aborted)
+			}
+			access.Info("HTTP request")
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+	})
+}
+
+// responseRecorder captures the status code and body size for the access log
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
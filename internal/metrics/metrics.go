@@ -1,9 +1,15 @@
 package metrics
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"kubernetes-api/internal/tracing"
+
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -14,7 +20,7 @@ var (
 	RequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
-			Help: "Total number of HTTP requests by method and path",
+			Help: "Total number of HTTP requests by method, route template and status code",
 		},
 		[]string{"method", "path", "status"},
 	)
@@ -29,6 +35,24 @@ var (
 		[]string{"method", "path"},
 	)
 
+	// RequestSize is a histogram for HTTP request body sizes
+	RequestSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "HTTP request body size in bytes",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 8),
+		},
+		[]string{"method", "path"},
+	)
+
+	// RequestsInFlight is a gauge for HTTP requests currently being served
+	RequestsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		},
+	)
+
 	// ResponseSize is a histogram for HTTP response sizes
 	ResponseSize = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -67,25 +91,91 @@ func PrometheusHandler() http.Handler {
 	return promhttp.Handler()
 }
 
-// MetricsMiddleware is a middleware that records HTTP request metrics
+// UnmatchedRoute is the path label for requests that matched no route, so
+// scans of unknown URLs share a single series
+const UnmatchedRoute = "unmatched"
+
+// MetricsMiddleware is a middleware that records HTTP request metrics labelled
+// with the mux route template. It is also used to wrap the router's NotFound and
+// MethodNotAllowed handlers, which are recorded under UnmatchedRoute.
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		RequestsInFlight.Inc()
+		defer RequestsInFlight.Dec()
 
 		// Wrap response writer to capture status code and size
 		metricsWriter := newMetricsResponseWriter(w)
 
+		// Count the body as it is read, for requests without a Content-Length
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+
+		// Record metrics, also when the handler panics to abort the response
+		defer func() {
+			aborted := recover()
+
+			requestSize := r.ContentLength
+			if requestSize < 0 {
+				requestSize = body.n
+			}
+
+			method, route := methodLabel(r.Method), routeLabel(r)
+			duration := time.Since(start).Seconds()
+			RequestDuration.WithLabelValues(method, route).Observe(duration)
+			RequestsTotal.WithLabelValues(method, route, strconv.Itoa(metricsWriter.statusCode)).Inc()
+			RequestSize.WithLabelValues(method, route).Observe(float64(requestSize))
+			ResponseSize.WithLabelValues(method, route).Observe(float64(metricsWriter.size))
+
+			if aborted != nil {
+				panic(aborted)
+			}
+		}()
+
 		// Call next handler
 		next.ServeHTTP(metricsWriter, r)
-
-		// Record metrics
-		duration := time.Since(start).Seconds()
-		RequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
-		RequestsTotal.WithLabelValues(r.Method, r.URL.Path, http.StatusText(metricsWriter.statusCode)).Inc()
-		ResponseSize.WithLabelValues(r.Method, r.URL.Path).Observe(float64(metricsWriter.size))
 	})
 }
 
+// routeLabel returns the path template of the matched route, e.g.
+// /api/v1/items/{id:[0-9]+}, or UnmatchedRoute
+func routeLabel(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return UnmatchedRoute
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return UnmatchedRoute
+	}
+	return template
+}
+
+// methodLabel maps non-standard methods to OTHER, since clients choose the method freely
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // metricsResponseWriter is a wrapper for http.ResponseWriter that captures status code and size
 type metricsResponseWriter struct {
 	http.ResponseWriter
@@ -114,11 +204,19 @@ func (w *metricsResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
-// TrackDatabaseOperation tracks a database operation duration
-func TrackDatabaseOperation(operation string, f func() error) error {
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// TrackDatabaseOperation tracks a database operation duration and traces it
+// as a child span of the request in ctx
+func TrackDatabaseOperation(ctx context.Context, operation string, f func() error) error {
+	_, span := tracing.StartDatabaseSpan(ctx, operation)
 	start := time.Now()
 	err := f()
 	duration := time.Since(start).Seconds()
+	tracing.EndSpan(span, err)
 
 	// Record metrics
 	DatabaseOperationDuration.WithLabelValues(operation).Observe(duration)
@@ -0,0 +1,323 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// advisoryLockID is the Postgres advisory lock key held while migrating, so
+// only one replica of the Deployment applies migrations at a time
+const advisoryLockID int64 = 7245190318
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load reads the embedded migrations ordered by version.
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", name, err)
+		}
+
+		content, err := fs.ReadFile(files, "sql/"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %q and %q", version, m.Name, parts[1])
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations
+func Up(ctx context.Context, db *sql.DB) error {
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, err := Load()
+		if err != nil {
+			return err
+		}
+
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Migrations read their settings with current_setting('migrations.<name>', true)
+		if _, err := conn.ExecContext(ctx,
+			"SELECT set_config('migrations.unowned_items_owner', $1, false)",
+			os.Getenv("UNOWNED_ITEMS_OWNER"),
+		); err != nil {
+			return fmt.Errorf("failed to pass migration settings: %w", err)
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			logrus.Infof("Applying migration %d_%s", m.Version, m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+					m.Version, m.Name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the given number of most recently applied migrations
+func Down(ctx context.Context, db *sql.DB, steps int) error {
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, err := Load()
+		if err != nil {
+			return err
+		}
+
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+
+			logrus.Infof("Rolling back migration %d_%s", m.Version, m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// CurrentStatus returns the applied state of every known migration
+func CurrentStatus(ctx context.Context, db *sql.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// CheckApplied returns an error unless every known migration has been applied.
+// Unlike CurrentStatus it never creates the bookkeeping table.
+func CheckApplied(ctx context.Context, db *sql.DB) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	var latest int
+	var count int
+	if err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version), 0), COUNT(*) FROM schema_migrations",
+	).Scan(&latest, &count); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	if pending := len(migrations) - count; pending > 0 {
+		return fmt.Errorf("%d migration(s) pending, schema is at version %d", pending, latest)
+	}
+	return nil
+}
+
+// withLock runs f on a dedicated connection holding the migration advisory lock
+func withLock(ctx context.Context, db *sql.DB, f func(conn *sql.Conn) error) error {
+	// Advisory locks are session scoped, so pin a single connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	logrus.Debug("Acquiring migration lock")
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			logrus.WithError(err).Warn("Failed to release migration lock")
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+// ensureTable creates the schema_migrations bookkeeping table if needed
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`)
+	return err
+}
+
+// appliedVersions returns the applied migration versions with their timestamps
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// inTx runs f inside a transaction on conn, rolling back on error
+func inTx(ctx context.Context, conn *sql.Conn, f func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logrus.WithError(rbErr).Warn("Failed to roll back migration transaction")
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
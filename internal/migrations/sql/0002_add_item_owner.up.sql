@@ -0,0 +1,23 @@
+-- Items are owned by the user who created them
+ALTER TABLE items ADD COLUMN IF NOT EXISTS owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+-- Items created before they had owners would be unreachable, so they are given to the user named
+-- by UNOWNED_ITEMS_OWNER; the migration fails if there are any and no such user
+DO $$
+DECLARE
+	owner_name TEXT := current_setting('migrations.unowned_items_owner', true);
+	owner_user_id INTEGER;
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM items WHERE owner_id IS NULL) THEN
+		RETURN;
+	END IF;
+
+	SELECT id INTO owner_user_id FROM users WHERE username = owner_name;
+	IF owner_user_id IS NULL THEN
+		RAISE EXCEPTION 'items without an owner exist; set UNOWNED_ITEMS_OWNER to the username that should own them';
+	END IF;
+	UPDATE items SET owner_id = owner_user_id WHERE owner_id IS NULL;
+END $$;
+
+ALTER TABLE items ALTER COLUMN owner_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_items_owner_id ON items (owner_id);
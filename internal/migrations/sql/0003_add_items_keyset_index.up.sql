@@ -0,0 +1,2 @@
+-- Supports keyset pagination of the list endpoint
+CREATE INDEX IF NOT EXISTS idx_items_owner_created ON items (owner_id, created_at, id);
@@ -0,0 +1,14 @@
+-- Token buckets shared by all replicas when RATE_LIMIT_STORE=postgres
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+	key TEXT PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Consecutive failed logins per username, used for lockout
+CREATE TABLE IF NOT EXISTS login_failures (
+	username TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
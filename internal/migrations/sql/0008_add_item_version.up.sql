@@ -0,0 +1,2 @@
+-- Incremented on every update, for optimistic concurrency control via ETags
+ALTER TABLE items ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_items_deleted_at;
+DELETE FROM items WHERE deleted_at IS NOT NULL;
+ALTER TABLE items DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+-- Deleted items stay in the trash until restored or purged
+ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+-- Supports listing the trash and finding items due for purging
+CREATE INDEX IF NOT EXISTS idx_items_deleted_at ON items (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,19 @@
+-- One row per change to an item; revision matches the item version it produced
+CREATE TABLE IF NOT EXISTS item_revisions (
+	item_id INTEGER NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	before_state JSONB,
+	after_state JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (item_id, revision)
+);
+
+-- Existing items start their history with a revision of their current state
+INSERT INTO item_revisions (item_id, revision, action, actor_id, after_state, created_at)
+SELECT id, version, 'create', owner_id,
+	jsonb_build_object('name', name, 'description', COALESCE(description, ''), 'deleted', deleted_at IS NOT NULL),
+	COALESCE(updated_at, NOW())
+FROM items
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- Full-text search over name (weighted higher) and description, kept current by Postgres on every write
+ALTER TABLE items ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', name), 'A') ||
+	setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_items_search ON items USING GIN (search_vector);
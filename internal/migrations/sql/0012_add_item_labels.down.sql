@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_items_labels;
+ALTER TABLE items DROP COLUMN IF EXISTS labels;
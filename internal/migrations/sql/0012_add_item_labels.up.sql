@@ -0,0 +1,3 @@
+-- Kubernetes-style labels; the GIN index serves equality (@>) and existence (?) selectors
+ALTER TABLE items ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_items_labels ON items USING GIN (labels);
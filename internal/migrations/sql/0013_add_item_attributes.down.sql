@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attribute_schemas;
+DROP INDEX IF EXISTS idx_items_attributes;
+ALTER TABLE items DROP COLUMN IF EXISTS attributes;
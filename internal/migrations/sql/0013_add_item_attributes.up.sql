@@ -0,0 +1,11 @@
+-- Free-form attributes; jsonb_path_ops serves the containment (@>) used by equality filters
+ALTER TABLE items ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_items_attributes ON items USING GIN (attributes jsonb_path_ops);
+
+-- JSON Schemas that the attributes of each namespace are validated against
+CREATE TABLE IF NOT EXISTS attribute_schemas (
+	namespace TEXT PRIMARY KEY,
+	schema JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
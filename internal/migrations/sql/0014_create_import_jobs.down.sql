@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS import_jobs;
+DROP INDEX IF EXISTS idx_items_owner_external_id;
+ALTER TABLE items DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,27 @@
+-- Optional key from an external system; imports update the item with a matching key instead of adding another
+ALTER TABLE items ADD COLUMN IF NOT EXISTS external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_items_owner_external_id ON items (owner_id, external_id) WHERE external_id IS NOT NULL;
+
+-- Asynchronous imports of items from uploaded files, with their progress and the lines that failed
+CREATE TABLE IF NOT EXISTS import_jobs (
+	id SERIAL PRIMARY KEY,
+	owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status TEXT NOT NULL,
+	format TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+	bytes_total BIGINT NOT NULL DEFAULT 0,
+	bytes_read BIGINT NOT NULL DEFAULT 0,
+	rows_read INTEGER NOT NULL DEFAULT 0,
+	created INTEGER NOT NULL DEFAULT 0,
+	updated INTEGER NOT NULL DEFAULT 0,
+	unchanged INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	errors JSONB NOT NULL DEFAULT '[]',
+	error TEXT,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	started_at TIMESTAMP WITH TIME ZONE,
+	finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_owner_id ON import_jobs (owner_id);
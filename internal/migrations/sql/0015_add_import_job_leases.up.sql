@@ -0,0 +1,6 @@
+-- Running imports are leased by the server instance processing them, which renews the heartbeat;
+-- jobs of other instances are only failed as abandoned once their lease has expired
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS instance_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_unfinished ON import_jobs (heartbeat_at) WHERE status IN ('queued', 'running');
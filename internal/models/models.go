@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"maps"
 	"time"
 )
 
@@ -9,11 +11,106 @@ type DataKey string
 
 // Item represents a basic entity in our application
 type Item struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int                    `json:"id"`
+	OwnerID     int                    `json:"owner_id"`
+	ExternalID  string                 `json:"external_id,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Labels      map[string]string      `json:"labels"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	Version     int                    `json:"version"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	DeletedAt   *time.Time             `json:"deleted_at,omitempty"`
+}
+
+// ItemSearchResult is an item matching a full-text search
+type ItemSearchResult struct {
+	Item
+	// Rank orders results by relevance; higher is better
+	Rank float64 `json:"rank"`
+	// Highlights are the name and a snippet of the description, HTML-escaped
+	// and with matched words wrapped in <mark> tags
+	Highlights ItemHighlights `json:"highlights"`
+}
+
+// ItemHighlights holds the highlighted fields of a search result
+type ItemHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// State returns the content of the item as recorded in its revisions
+func (i Item) State() ItemState {
+	return ItemState{Name: i.Name, Description: i.Description, Labels: maps.Clone(i.Labels), Attributes: maps.Clone(i.Attributes), Deleted: i.DeletedAt != nil}
+}
+
+// RevisionAction names the kind of change an item revision records
+type RevisionAction string
+
+const (
+	// RevisionCreate records the creation of an item
+	RevisionCreate RevisionAction = "create"
+	// RevisionUpdate records a PUT or PATCH
+	RevisionUpdate RevisionAction = "update"
+	// RevisionDelete records a move to the trash
+	RevisionDelete RevisionAction = "delete"
+	// RevisionRestore records a restore from the trash
+	RevisionRestore RevisionAction = "restore"
+	// RevisionRevert records a revert to the state of an earlier revision
+	RevisionRevert RevisionAction = "revert"
+)
+
+// ItemState is the content of an item at one revision
+type ItemState struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	Deleted     bool                   `json:"deleted"`
+}
+
+// ItemRevision records one change to an item. Revision equals the item
+// version the change produced.
+type ItemRevision struct {
+	ItemID    int            `json:"item_id"`
+	Revision  int            `json:"revision"`
+	Action    RevisionAction `json:"action"`
+	ActorID   *int           `json:"actor_id"`
+	Before    *ItemState     `json:"before"`
+	After     ItemState      `json:"after"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ItemChange is one field that differs between two revisions
+type ItemChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Role names a set of permissions granted to a user
+type Role string
+
+const (
+	// RoleAdmin can manage users in addition to everything an editor can do
+	RoleAdmin Role = "admin"
+	// RoleEditor can create, update and delete their own items
+	RoleEditor Role = "editor"
+	// RoleViewer can only read their own items
+	RoleViewer Role = "viewer"
+
+	// DefaultRole is assigned to newly registered users
+	DefaultRole = RoleEditor
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
 }
 
 // User represents a user in our system
@@ -22,39 +119,231 @@ type User struct {
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"-"` // Password hash is not exposed via JSON
 	Email        string    `json:"email"`
+	Role         Role      `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// Scope limits what an API key may be used for
+type Scope string
+
+const (
+	// ScopeItemsRead allows reading items
+	ScopeItemsRead Scope = "items:read"
+	// ScopeItemsWrite allows creating, updating and deleting items
+	ScopeItemsWrite Scope = "items:write"
+	// ScopeUsersManage allows the admin user-management endpoints
+	ScopeUsersManage Scope = "users:manage"
+)
+
+// Valid reports whether s is a known scope
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeItemsRead, ScopeItemsWrite, ScopeUsersManage:
+		return true
+	}
+	return false
+}
+
+// APIKey is a long-lived credential for service-to-service calls. The key
+// itself is only returned once, at creation.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []Scope    `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Owner is the user the key acts as, filled in on lookup
+	Owner User `json:"-"`
+}
+
+// APIKeyRequest is used to create an API key
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100,printable"`
+	Scopes    []Scope    `json:"scopes" validate:"required,oneof=items:read items:write users:manage"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Session is a login session; all refresh tokens rotated from one login share it
+type Session struct {
+	ID        string     `json:"id"`
+	UserID    int        `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
 // LoginRequest represents the login request body
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
 }
 
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Username string `json:"username" validate:"required,min=3,max=32,username"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72"` // bcrypt ignores bytes past 72
+	Email    string `json:"email" validate:"required,max=254,email"`
+}
+
+// RoleRequest is used to change a user's role
+type RoleRequest struct {
+	Role Role `json:"role" validate:"required,oneof=admin editor viewer"`
+}
+
+// RefreshRequest carries a refresh token for the refresh and logout endpoints
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=256"`
 }
 
 // AuthResponse represents the authentication response with JWT token
 type AuthResponse struct {
-	Token   string `json:"token"`
-	User    User   `json:"user"`
-	Message string `json:"message"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	User         User      `json:"user"`
+	Message      string    `json:"message"`
 }
 
-// ApiResponse is a generic response structure
+// ApiResponse is a generic response structure for successful requests.
+// Failures are rendered as application/problem+json by the problem package.
 type ApiResponse struct {
 	Status  string                  `json:"status"`
 	Message string                  `json:"message,omitempty"`
 	Data    map[DataKey]interface{} `json:"data,omitempty"`
-	Error   string                  `json:"error,omitempty"`
+}
+
+// Pagination describes the position of a page within a list response
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
 }
 
 // ItemRequest is used for item creation/update requests
 type ItemRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string `json:"name" validate:"required,max=200,printable"`
+	Description string `json:"description" validate:"max=2000"`
+	// Labels and Attributes replace the item's; omitting them on update keeps the current ones
+	Labels     map[string]string      `json:"labels" validate:"labels"`
+	Attributes map[string]interface{} `json:"attributes" validate:"attributes"`
+}
+
+// BatchMode selects how a batch request treats failing entries
+type BatchMode string
+
+const (
+	// BatchAtomic writes every entry in one transaction, or none if one fails
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort writes each entry on its own and reports each outcome
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// BatchCreateRequest is used to create several items at once
+type BatchCreateRequest struct {
+	Mode  BatchMode     `json:"mode"`
+	Items []ItemRequest `json:"items"`
+}
+
+// BatchUpdateEntry replaces one item like a PUT. A non-zero Version makes the
+// update conditional, like If-Match.
+type BatchUpdateEntry struct {
+	ID      int `json:"id"`
+	Version int `json:"version"`
+	ItemRequest
+}
+
+// BatchUpdateRequest is used to update several items at once
+type BatchUpdateRequest struct {
+	Mode  BatchMode          `json:"mode"`
+	Items []BatchUpdateEntry `json:"items"`
+}
+
+// BatchDeleteEntry moves one item to the trash. A non-zero Version makes the
+// delete conditional, like If-Match.
+type BatchDeleteEntry struct {
+	ID      int `json:"id"`
+	Version int `json:"version"`
+}
+
+// BatchDeleteRequest is used to delete several items at once
+type BatchDeleteRequest struct {
+	Mode  BatchMode          `json:"mode"`
+	Items []BatchDeleteEntry `json:"items"`
+}
+
+// AttributeSchema is the JSON Schema the attributes of one namespace are
+// validated against
+type AttributeSchema struct {
+	Namespace string          `json:"namespace"`
+	Schema    json.RawMessage `json:"schema"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ImportRow is one record of an import file. Rows with an ExternalID update
+// the item with that key, if there is one, instead of adding another.
+type ImportRow struct {
+	ExternalID string `json:"external_id" validate:"max=255,printable"`
+	ItemRequest
+}
+
+// ImportStatus is the state of an import job
+type ImportStatus string
+
+const (
+	// ImportQueued jobs have been accepted and wait to be processed
+	ImportQueued ImportStatus = "queued"
+	// ImportRunning jobs are being processed
+	ImportRunning ImportStatus = "running"
+	// ImportCompleted jobs have read the whole file, though some rows may have failed
+	ImportCompleted ImportStatus = "completed"
+	// ImportFailed jobs were stopped by an error, described in Error
+	ImportFailed ImportStatus = "failed"
+)
+
+// ImportInterrupted is the Error of jobs stopped by a shutdown or restart
+const ImportInterrupted = "Interrupted by shutdown; run the import again"
+
+// ImportError describes a row of an import that was not applied
+type ImportError struct {
+	Line       int    `json:"line"`
+	ExternalID string `json:"external_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ImportJob tracks the asynchronous import of items from an uploaded file.
+// Dry runs validate the file and count what would change without writing.
+type ImportJob struct {
+	ID       int          `json:"id"`
+	OwnerID  int          `json:"owner_id"`
+	Status   ImportStatus `json:"status"`
+	Format   string       `json:"format"`
+	Filename string       `json:"filename"`
+	DryRun   bool         `json:"dry_run"`
+	// BytesRead of BytesTotal tracks progress through the file
+	BytesTotal int64 `json:"bytes_total"`
+	BytesRead  int64 `json:"bytes_read"`
+	// RowsRead counts the rows processed so far, each counted once in
+	// Created, Updated, Unchanged or Failed
+	RowsRead  int `json:"rows_read"`
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+	// Errors lists the first failed rows
+	Errors []ImportError `json:"errors"`
+	// Error tells why a failed job stopped
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// InstanceID is the server instance running the job, which renews
+	// HeartbeatAt while it does
+	InstanceID  string    `json:"-"`
+	HeartbeatAt time.Time `json:"-"`
 }
@@ -0,0 +1,88 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/validation"
+	"kubernetes-api/pkg/utils"
+)
+
+// ContentType is the media type of RFC 7807 problem documents
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. It is the single error type
+// rendered for every failed request.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Errors lists every invalid field of a rejected request body
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// New creates a problem for the given status code. The title is the standard
+// status text and the type is about:blank, as RFC 7807 recommends when no more
+// specific type is defined.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error implements the error interface
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+// Write renders p as application/problem+json, filling in the instance and
+// request ID from the request
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID, _ = r.Context().Value(utils.RequestIDKey).(string)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to encode problem response")
+	}
+}
+
+// Error replies to the request with a problem for the given status and detail.
+// It is the problem+json counterpart of http.Error.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	Write(w, r, New(status, detail))
+}
+
+// Validation replies with a 422 problem listing every invalid field
+func Validation(w http.ResponseWriter, r *http.Request, errs validation.Errors) {
+	p := New(http.StatusUnprocessableEntity, "Request validation failed")
+	p.Errors = errs
+	Write(w, r, p)
+}
+
+// NotFoundHandler renders 404 problems for unmatched routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusNotFound, "Resource not found")
+}
+
+// MethodNotAllowedHandler renders 405 problems for routes matched with the wrong method
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed on this resource")
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// failureWindow is how long a failed login counts towards a lockout. A failure
+// after a quiet period this long starts counting from one again.
+const failureWindow = 24 * time.Hour
+
+// LockoutPolicy decides how long a username is locked out after failed logins
+type LockoutPolicy struct {
+	// MaxFailures is the number of consecutive failures allowed before locking; 0 disables lockout
+	MaxFailures int
+	// BaseDelay is the lockout after MaxFailures failures; it doubles with each further failure
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout
+	MaxDelay time.Duration
+}
+
+// Delay returns the lockout following the given number of consecutive failures
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.MaxFailures; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// remaining returns how much of the lockout following the last failure is left
+func (p LockoutPolicy) remaining(failures int, lastFailure time.Time) time.Duration {
+	return max(0, time.Until(lastFailure.Add(p.Delay(failures))))
+}
+
+// Lockout tracks consecutive failed logins per username
+type Lockout interface {
+	// Check returns how long the username is still locked out for
+	Check(ctx context.Context, username string) (time.Duration, error)
+	// Fail records a failed login and returns the resulting lockout
+	Fail(ctx context.Context, username string) (time.Duration, error)
+	// Reset clears the failures after a successful login
+	Reset(ctx context.Context, username string) error
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle entries are dropped from the memory stores
+const sweepInterval = time.Minute
+
+// bucket is the in-memory state of a token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// refill returns the tokens in the bucket at now
+func (b *bucket) refill(now time.Time) float64 {
+	return min(float64(b.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate)
+}
+
+// MemoryStore is a Store local to one replica, for single-replica and local runs
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = b.refill(now)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, b.tokens, allowed), nil
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// indistinguishable from new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.refill(now) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// memoryFailures is the in-memory record of a username's failed logins
+type memoryFailures struct {
+	count int
+	last  time.Time
+}
+
+// MemoryLockout is a Lockout local to one replica, for single-replica and local runs
+type MemoryLockout struct {
+	mu        sync.Mutex
+	policy    LockoutPolicy
+	failures  map[string]memoryFailures
+	lastSweep time.Time
+}
+
+// NewMemoryLockout creates an empty MemoryLockout
+func NewMemoryLockout(policy LockoutPolicy) *MemoryLockout {
+	return &MemoryLockout{policy: policy, failures: map[string]memoryFailures{}, lastSweep: time.Now()}
+}
+
+// Check implements Lockout
+func (l *MemoryLockout) Check(ctx context.Context, username string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f := l.failures[username]
+	return l.policy.remaining(f.count, f.last), nil
+}
+
+// Fail implements Lockout
+func (l *MemoryLockout) Fail(ctx context.Context, username string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	f := l.failures[username]
+	if now.Sub(f.last) > failureWindow {
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+	l.failures[username] = f
+
+	// Drop usernames whose failures have expired
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.lastSweep = now
+		for name, other := range l.failures {
+			if now.Sub(other.last) > failureWindow {
+				delete(l.failures, name)
+			}
+		}
+	}
+
+	return l.policy.remaining(f.count, f.last), nil
+}
+
+// Reset implements Lockout
+func (l *MemoryLockout) Reset(ctx context.Context, username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, username)
+	return nil
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"kubernetes-api/internal/metrics"
+)
+
+// PostgresStore is a Store shared by every replica, so limits hold when the
+// Deployment is scaled out
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take implements Store. The refill and take happen in a single upsert, so
+// concurrent requests for the same key cannot overspend the bucket.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var tokens float64
+	var allowed bool
+	err := metrics.TrackDatabaseOperation(ctx, "rate_limit_take", func() error {
+		// Every SET expression sees the row as it was before the update
+		return s.db.QueryRowContext(ctx, `
+			INSERT INTO rate_limits AS b (key, tokens, allowed, updated_at) VALUES ($1, $2::float8 - 1, TRUE, NOW())
+			ON CONFLICT (key) DO UPDATE SET
+				tokens = CASE
+					WHEN LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::float8) >= 1
+					THEN LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3) - 1
+					ELSE LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3)
+				END,
+				allowed = LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3) >= 1,
+				updated_at = NOW()
+			RETURNING tokens, allowed`,
+			key, float64(limit.Burst), limit.Rate,
+		).Scan(&tokens, &allowed)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, tokens, allowed), nil
+}
+
+// Purge deletes buckets untouched for longer than idle
+func (s *PostgresStore) Purge(ctx context.Context, idle time.Duration) error {
+	return metrics.TrackDatabaseOperation(ctx, "rate_limit_purge", func() error {
+		_, err := s.db.ExecContext(ctx,
+			"DELETE FROM rate_limits WHERE updated_at < NOW() - make_interval(secs => $1)",
+			idle.Seconds(),
+		)
+		return err
+	})
+}
+
+// PostgresLockout is a Lockout shared by every replica
+type PostgresLockout struct {
+	db     *sql.DB
+	policy LockoutPolicy
+}
+
+// NewPostgresLockout creates a new PostgresLockout
+func NewPostgresLockout(db *sql.DB, policy LockoutPolicy) *PostgresLockout {
+	return &PostgresLockout{db: db, policy: policy}
+}
+
+// Check implements Lockout
+func (l *PostgresLockout) Check(ctx context.Context, username string) (time.Duration, error) {
+	var failures int
+	var lastFailure time.Time
+	err := metrics.TrackDatabaseOperation(ctx, "get_login_failures", func() error {
+		return l.db.QueryRowContext(ctx,
+			"SELECT failures, last_failure_at FROM login_failures WHERE username = $1",
+			username,
+		).Scan(&failures, &lastFailure)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return l.policy.remaining(failures, lastFailure), nil
+}
+
+// Fail implements Lockout
+func (l *PostgresLockout) Fail(ctx context.Context, username string) (time.Duration, error) {
+	var failures int
+	var lastFailure time.Time
+	err := metrics.TrackDatabaseOperation(ctx, "record_login_failure", func() error {
+		return l.db.QueryRowContext(ctx, `
+			INSERT INTO login_failures AS f (username, failures, last_failure_at) VALUES ($1, 1, NOW())
+			ON CONFLICT (username) DO UPDATE SET
+				failures = CASE
+					WHEN f.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+					ELSE f.failures + 1
+				END,
+				last_failure_at = NOW()
+			RETURNING failures, last_failure_at`,
+			username, failureWindow.Seconds(),
+		).Scan(&failures, &lastFailure)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return l.policy.remaining(failures, lastFailure), nil
+}
+
+// Reset implements Lockout
+func (l *PostgresLockout) Reset(ctx context.Context, username string) error {
+	return metrics.TrackDatabaseOperation(ctx, "reset_login_failures", func() error {
+		_, err := l.db.ExecContext(ctx, "DELETE FROM login_failures WHERE username = $1", username)
+		return err
+	})
+}
+
+// Purge deletes failures that no longer count towards a lockout
+func (l *PostgresLockout) Purge(ctx context.Context) error {
+	return metrics.TrackDatabaseOperation(ctx, "purge_login_failures", func() error {
+		_, err := l.db.ExecContext(ctx,
+			"DELETE FROM login_failures WHERE last_failure_at < NOW() - make_interval(secs => $1)",
+			failureWindow.Seconds(),
+		)
+		return err
+	})
+}
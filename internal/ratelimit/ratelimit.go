@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/problem"
+	"kubernetes-api/pkg/utils"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second.
+// The zero Limit disables limiting.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// ParseLimit parses limits of the form "20/m": a burst of 20 refilled over a
+// minute. The units s, m and h are accepted, and "off" disables the limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected e.g. 20/m", s)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit unit in %q, expected s, m or h", s)
+	}
+
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}, nil
+}
+
+// Result is the state of a bucket after a request has been counted
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, when denied
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// newResult derives a Result from the tokens left in a bucket
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:    allowed,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		ResetAfter: secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return result
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Max(0, seconds) * float64(time.Second))
+}
+
+// Store holds token buckets
+type Store interface {
+	// Take removes one token from the bucket for key, if one is available
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// KeyFunc returns the bucket key of a request
+type KeyFunc func(r *http.Request, clientIP string) string
+
+// ByIP keys buckets by client IP address
+func ByIP(r *http.Request, clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// ByUser keys buckets by the authenticated user, falling back to the client IP.
+// It must run after auth.AuthMiddleware.
+func ByUser(r *http.Request, clientIP string) string {
+	if userID, ok := r.Context().Value(utils.UserIDKey).(int); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return ByIP(r, clientIP)
+}
+
+// Limiter applies token bucket limits to HTTP routes. A nil Limiter does not
+// limit anything.
+type Limiter struct {
+	store Store
+	// trustedProxyHops is the number of proxies in front of the service that
+	// append to X-Forwarded-For
+	trustedProxyHops int
+}
+
+// NewLimiter creates a Limiter backed by store
+func NewLimiter(store Store, trustedProxyHops int) *Limiter {
+	return &Limiter{store: store, trustedProxyHops: trustedProxyHops}
+}
+
+// Middleware returns a middleware that limits requests to the named route group,
+// bucketed by key. Denied requests get a 429 with Retry-After; every response
+// carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// Requests are let through when the store fails.
+func (l *Limiter) Middleware(name string, limit Limit, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || !limit.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bucket := name + ":" + key(r, l.ClientIP(r))
+			result, err := l.store.Take(r.Context(), bucket, limit)
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).Warn("Rate limit store failed, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				SetRetryAfter(w, result.RetryAfter)
+				problem.Error(w, r, http.StatusTooManyRequests, "Rate limit exceeded, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the address of the client, read from X-Forwarded-For when
+// the service runs behind trusted proxies
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.trustedProxyHops > 0 {
+		// Each trusted proxy appends the address it received the request from,
+		// so the client is the entry that many hops from the right
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(header, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		if i := len(hops) - l.trustedProxyHops; i >= 0 && i < len(hops) && net.ParseIP(hops[i]) != nil {
+			return hops[i]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(d))))
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Config is the rate limiting configuration read from the environment
+type Config struct {
+	// Store is "memory" (per replica) or "postgres" (shared by all replicas)
+	Store string
+	// Auth limits the public auth endpoints per client IP
+	Auth Limit
+	// Login and Register limit those two auth endpoints per client IP, in
+	// buckets of their own; they default to Auth
+	Login    Limit
+	Register Limit
+	// API limits authenticated endpoints per user
+	API Limit
+	// TrustedProxyHops is the number of proxies appending to X-Forwarded-For
+	TrustedProxyHops int
+	// Lockout locks usernames out after repeated failed logins
+	Lockout LockoutPolicy
+}
+
+// ConfigFromEnv reads the rate limiting configuration from the environment
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	var err error
+
+	cfg.Store = utils.GetEnv("RATE_LIMIT_STORE", "memory")
+	if cfg.Store != "memory" && cfg.Store != "postgres" {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_STORE %q, expected memory or postgres", cfg.Store)
+	}
+	auth := utils.GetEnv("RATE_LIMIT_AUTH", "20/m")
+	if cfg.Auth, err = ParseLimit(auth); err != nil {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_AUTH: %w", err)
+	}
+	if cfg.Login, err = ParseLimit(utils.GetEnv("RATE_LIMIT_LOGIN", auth)); err != nil {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_LOGIN: %w", err)
+	}
+	if cfg.Register, err = ParseLimit(utils.GetEnv("RATE_LIMIT_REGISTER", auth)); err != nil {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_REGISTER: %w", err)
+	}
+	if cfg.API, err = ParseLimit(utils.GetEnv("RATE_LIMIT_API", "600/m")); err != nil {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_API: %w", err)
+	}
+	if cfg.TrustedProxyHops, err = strconv.Atoi(utils.GetEnv("RATE_LIMIT_TRUSTED_PROXY_HOPS", "0")); err != nil || cfg.TrustedProxyHops < 0 {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_TRUSTED_PROXY_HOPS")
+	}
+
+	if cfg.Lockout.MaxFailures, err = strconv.Atoi(utils.GetEnv("LOGIN_MAX_FAILURES", "5")); err != nil || cfg.Lockout.MaxFailures < 0 {
+		return cfg, fmt.Errorf("invalid LOGIN_MAX_FAILURES")
+	}
+	if cfg.Lockout.BaseDelay, err = time.ParseDuration(utils.GetEnv("LOGIN_LOCKOUT_BASE", "1m")); err != nil {
+		return cfg, fmt.Errorf("invalid LOGIN_LOCKOUT_BASE: %w", err)
+	}
+	if cfg.Lockout.MaxDelay, err = time.ParseDuration(utils.GetEnv("LOGIN_LOCKOUT_MAX", "1h")); err != nil {
+		return cfg, fmt.Errorf("invalid LOGIN_LOCKOUT_MAX: %w", err)
+	}
+
+	return cfg, nil
+}
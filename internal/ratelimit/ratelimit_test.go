@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Limit
+	}{
+		{"", Limit{}},
+		{"off", Limit{}},
+		{"1/s", Limit{Rate: 1, Burst: 1}},
+		{"20/m", Limit{Rate: 20.0 / 60, Burst: 20}},
+		{"30/s", Limit{Rate: 30, Burst: 30}},
+		{"3600/h", Limit{Rate: 1, Burst: 3600}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLimit(tt.input)
+			if err != nil {
+				t.Fatalf("ParseLimit(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+			if got.Enabled() != (tt.want.Burst > 0) {
+				t.Errorf("ParseLimit(%q).Enabled() = %v", tt.input, got.Enabled())
+			}
+		})
+	}
+}
+
+func TestParseLimitErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"no slash", "20", "expected e.g. 20/m"},
+		{"no count", "/m", "expected e.g. 20/m"},
+		{"zero", "0/m", "expected e.g. 20/m"},
+		{"negative", "-5/m", "expected e.g. 20/m"},
+		{"fraction", "1.5/s", "expected e.g. 20/m"},
+		{"spaces", " 20/m", "expected e.g. 20/m"},
+		{"upper case off", "OFF", "expected e.g. 20/m"},
+		{"no unit", "20/", "expected s, m or h"},
+		{"unknown unit", "20/d", "expected s, m or h"},
+		{"long unit", "20/min", "expected s, m or h"},
+		{"second slash", "20/m/s", "expected s, m or h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLimit(tt.input)
+			if err == nil {
+				t.Fatalf("ParseLimit(%q) succeeded, want error containing %q", tt.input, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseLimit(%q) error = %q, want it to contain %q", tt.input, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+
+	tests := []struct {
+		name     string
+		policy   LockoutPolicy
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", policy, 0, 0},
+		{"below limit", policy, 2, 0},
+		{"at limit", policy, 3, time.Minute},
+		{"one over", policy, 4, 2 * time.Minute},
+		{"two over", policy, 5, 4 * time.Minute},
+		{"three over", policy, 6, 8 * time.Minute},
+		{"capped", policy, 7, 10 * time.Minute},
+		{"far over", policy, 1000, 10 * time.Minute},
+		{"disabled", LockoutPolicy{BaseDelay: time.Minute, MaxDelay: time.Hour}, 100, 0},
+		{"base above max", LockoutPolicy{MaxFailures: 1, BaseDelay: time.Hour, MaxDelay: time.Minute}, 1, time.Minute},
+		{"zero base", LockoutPolicy{MaxFailures: 1, MaxDelay: time.Minute}, 5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.failures); got != tt.want {
+				t.Errorf("Delay(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLockout(t *testing.T) {
+	ctx := context.Background()
+	lockout := NewMemoryLockout(LockoutPolicy{MaxFailures: 2, BaseDelay: time.Minute, MaxDelay: time.Hour})
+
+	// The lockout starts at the second failure and doubles with the third
+	for i, want := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		got, err := lockout.Fail(ctx, "alice")
+		if err != nil {
+			t.Fatalf("Fail returned error: %v", err)
+		}
+		if got > want || got < want-time.Second {
+			t.Errorf("failure %d: lockout = %s, want %s", i+1, got, want)
+		}
+	}
+
+	if remaining, _ := lockout.Check(ctx, "alice"); remaining <= time.Minute {
+		t.Errorf("Check after 3 failures = %s, want about 2m", remaining)
+	}
+	if remaining, _ := lockout.Check(ctx, "bob"); remaining != 0 {
+		t.Errorf("Check of another username = %s, want 0", remaining)
+	}
+
+	if err := lockout.Reset(ctx, "alice"); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	if remaining, _ := lockout.Check(ctx, "alice"); remaining != 0 {
+		t.Errorf("Check after Reset = %s, want 0", remaining)
+	}
+}
@@ -0,0 +1,676 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"html"
+	"maps"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"kubernetes-api/internal/models"
+)
+
+// MemoryItemRepository is an in-memory ItemRepository for tests and local runs
+type MemoryItemRepository struct {
+	mu        sync.RWMutex
+	items     map[int]models.Item
+	revisions map[int][]models.ItemRevision
+	nextID    int
+}
+
+// NewMemoryItemRepository creates an empty MemoryItemRepository
+func NewMemoryItemRepository() *MemoryItemRepository {
+	return &MemoryItemRepository{
+		items:     map[int]models.Item{},
+		revisions: map[int][]models.ItemRevision{},
+		nextID:    1,
+	}
+}
+
+// List implements ItemRepository
+func (r *MemoryItemRepository) List(ctx context.Context, opts ItemListOptions) ([]models.Item, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	field := opts.SortField
+	if !opts.sortFields()[field] {
+		field = "created_at"
+	}
+
+	items := []models.Item{}
+	for _, item := range r.items {
+		if item.OwnerID != opts.OwnerID || (item.DeletedAt != nil) != opts.Trashed || !matchesListOptions(item, opts) {
+			continue
+		}
+		if opts.After != nil {
+			c := compareSortKey(item, field, opts.After.Value, opts.After.ID)
+			if (!opts.SortDesc && c <= 0) || (opts.SortDesc && c >= 0) {
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		c := compareSortKey(items[i], field, sortKey(items[j], field), items[j].ID)
+		if opts.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	hasMore := len(items) > opts.Limit
+	if hasMore {
+		items = items[:opts.Limit]
+	}
+
+	return items, hasMore, nil
+}
+
+// Export implements ItemRepository
+func (r *MemoryItemRepository) Export(ctx context.Context, opts ItemListOptions, fn func(models.Item) error) error {
+	opts.Limit = math.MaxInt
+	items, _, err := r.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search implements ItemRepository. Words are matched by prefix without the
+// stemming Postgres applies, and ranks are a weighted count of matches.
+func (r *MemoryItemRepository) Search(ctx context.Context, opts ItemListOptions) ([]models.ItemSearchResult, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	field := opts.SortField
+	if !opts.sortFields()[field] {
+		field = "created_at"
+	}
+	terms := SearchTerms(opts.Search)
+
+	// compare orders a result against a (sort key, id) position
+	compare := func(result models.ItemSearchResult, value interface{}, id int) int {
+		if field != "relevance" {
+			return compareSortKey(result.Item, field, value, id)
+		}
+		if c := cmp.Compare(result.Rank, value.(float64)); c != 0 {
+			return c
+		}
+		return cmp.Compare(result.ID, id)
+	}
+	key := func(result models.ItemSearchResult) interface{} {
+		if field == "relevance" {
+			return result.Rank
+		}
+		return sortKey(result.Item, field)
+	}
+
+	results := []models.ItemSearchResult{}
+	for _, item := range r.items {
+		if item.OwnerID != opts.OwnerID || item.DeletedAt != nil || !matchesListOptions(item, opts) {
+			continue
+		}
+		rank, ok := rankItem(item, terms)
+		if !ok {
+			continue
+		}
+		result := models.ItemSearchResult{
+			Item: item,
+			Rank: rank,
+			Highlights: models.ItemHighlights{
+				Name:        highlightTerms(item.Name, terms),
+				Description: highlightTerms(item.Description, terms),
+			},
+		}
+		if opts.After != nil {
+			c := compare(result, opts.After.Value, opts.After.ID)
+			if (!opts.SortDesc && c <= 0) || (opts.SortDesc && c >= 0) {
+				continue
+			}
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		c := compare(results[i], key(results[j]), results[j].ID)
+		if opts.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	hasMore := len(results) > opts.Limit
+	if hasMore {
+		results = results[:opts.Limit]
+	}
+
+	return results, hasMore, nil
+}
+
+// rankItem reports whether every term prefixes a word of the item, and ranks
+// the item by its matches, counting name matches higher
+func rankItem(item models.Item, terms []string) (float64, bool) {
+	names, descriptions := SearchTerms(item.Name), SearchTerms(item.Description)
+	rank := 0.0
+	for _, term := range terms {
+		matches := 0.0
+		for _, word := range names {
+			if strings.HasPrefix(word, term) {
+				matches++
+			}
+		}
+		for _, word := range descriptions {
+			if strings.HasPrefix(word, term) {
+				matches += 0.4
+			}
+		}
+		if matches == 0 {
+			return 0, false
+		}
+		rank += matches
+	}
+	return rank, true
+}
+
+// highlightTerms HTML-escapes text and wraps its words that start with a term
+// in <mark> tags
+func highlightTerms(text string, terms []string) string {
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		lower := strings.ToLower(string(word))
+		for _, term := range terms {
+			if strings.HasPrefix(lower, term) {
+				b.WriteString("<mark>" + string(word) + "</mark>")
+				word = word[:0]
+				return
+			}
+		}
+		b.WriteString(string(word))
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+	return b.String()
+}
+
+// Get implements ItemRepository
+func (r *MemoryItemRepository) Get(ctx context.Context, ownerID, id int) (models.Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.items[id]
+	if !ok || item.OwnerID != ownerID || item.DeletedAt != nil {
+		return models.Item{}, ErrNotFound
+	}
+	return item, nil
+}
+
+// GetByExternalID implements ItemRepository
+func (r *MemoryItemRepository) GetByExternalID(ctx context.Context, ownerID int, externalID string) (models.Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, item := range r.items {
+		if item.OwnerID == ownerID && item.ExternalID == externalID {
+			return item, nil
+		}
+	}
+	return models.Item{}, ErrNotFound
+}
+
+// Create implements ItemRepository
+func (r *MemoryItemRepository) Create(ctx context.Context, item *models.Item) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.create(item)
+}
+
+// create stores a new item. The caller holds the lock.
+func (r *MemoryItemRepository) create(item *models.Item) error {
+	if item.ExternalID != "" {
+		for _, other := range r.items {
+			if other.OwnerID == item.OwnerID && other.ExternalID == item.ExternalID {
+				return fmt.Errorf("%w: external_id %q is already in use", ErrConflict, item.ExternalID)
+			}
+		}
+	}
+
+	now := time.Now()
+	item.ID = r.nextID
+	item.Version = 1
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	item.Labels = maps.Clone(item.Labels)
+	if item.Labels == nil {
+		item.Labels = map[string]string{}
+	}
+	item.Attributes = maps.Clone(item.Attributes)
+	if item.Attributes == nil {
+		item.Attributes = map[string]interface{}{}
+	}
+	r.nextID++
+	r.items[item.ID] = *item
+	r.record(models.RevisionCreate, nil, *item)
+	return nil
+}
+
+// Update implements ItemRepository
+func (r *MemoryItemRepository) Update(ctx context.Context, item *models.Item) error {
+	update := *item
+	return r.modify(models.RevisionUpdate, item, false, func(current *models.Item) error {
+		applyUpdate(current, update)
+		return nil
+	})
+}
+
+// Patch implements ItemRepository
+func (r *MemoryItemRepository) Patch(ctx context.Context, item *models.Item, apply func(*models.Item) error) error {
+	return r.modify(models.RevisionUpdate, item, false, apply)
+}
+
+// Delete implements ItemRepository
+func (r *MemoryItemRepository) Delete(ctx context.Context, ownerID, id, version int) error {
+	item := models.Item{ID: id, OwnerID: ownerID, Version: version}
+	return r.modify(models.RevisionDelete, &item, false, trashItem)
+}
+
+// trashItem is the change Delete applies
+func trashItem(current *models.Item) error {
+	now := time.Now()
+	current.DeletedAt = &now
+	return nil
+}
+
+// Restore implements ItemRepository
+func (r *MemoryItemRepository) Restore(ctx context.Context, ownerID, id int) (models.Item, error) {
+	item := models.Item{ID: id, OwnerID: ownerID}
+	err := r.modify(models.RevisionRestore, &item, true, func(current *models.Item) error {
+		current.DeletedAt = nil
+		return nil
+	})
+	return item, err
+}
+
+// Revert implements ItemRepository
+func (r *MemoryItemRepository) Revert(ctx context.Context, item *models.Item, revision int) error {
+	return r.modify(models.RevisionRevert, item, false, func(current *models.Item) error {
+		for _, rev := range r.revisions[current.ID] {
+			if rev.Revision == revision {
+				current.Name, current.Description = rev.After.Name, rev.After.Description
+				current.Labels, current.Attributes = rev.After.Labels, rev.After.Attributes
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// modify lets change edit a copy of a live item, or of an item in the trash
+// when trashed is set, and stores it as the next version with a revision
+func (r *MemoryItemRepository) modify(action models.RevisionAction, item *models.Item, trashed bool, change func(*models.Item) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.modifyLocked(action, item, trashed, change)
+}
+
+// modifyLocked is modify for callers holding the lock
+func (r *MemoryItemRepository) modifyLocked(action models.RevisionAction, item *models.Item, trashed bool, change func(*models.Item) error) error {
+	stored, ok := r.items[item.ID]
+	if !ok || stored.OwnerID != item.OwnerID || (stored.DeletedAt != nil) != trashed {
+		return ErrNotFound
+	}
+	if item.Version != 0 && item.Version != stored.Version {
+		return ErrVersionMismatch
+	}
+
+	// Change a copy so a failed change leaves the item untouched
+	changed := stored
+	if err := change(&changed); err != nil {
+		return err
+	}
+
+	changed.ID, changed.OwnerID, changed.CreatedAt = stored.ID, stored.OwnerID, stored.CreatedAt
+	changed.Labels = maps.Clone(changed.Labels)
+	if changed.Labels == nil {
+		changed.Labels = map[string]string{}
+	}
+	changed.Attributes = maps.Clone(changed.Attributes)
+	if changed.Attributes == nil {
+		changed.Attributes = map[string]interface{}{}
+	}
+	changed.Version = stored.Version + 1
+	if action == models.RevisionUpdate || action == models.RevisionRevert {
+		changed.UpdatedAt = time.Now()
+	}
+	r.items[item.ID] = changed
+	r.record(action, &stored, changed)
+	*item = changed
+	return nil
+}
+
+// CreateBatch implements ItemRepository
+func (r *MemoryItemRepository) CreateBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(items, r.create)
+}
+
+// UpdateBatch implements ItemRepository
+func (r *MemoryItemRepository) UpdateBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(items, func(item *models.Item) error {
+		update := *item
+		return r.modifyLocked(models.RevisionUpdate, item, false, func(current *models.Item) error {
+			applyUpdate(current, update)
+			return nil
+		})
+	})
+}
+
+// DeleteBatch implements ItemRepository
+func (r *MemoryItemRepository) DeleteBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(items, func(item *models.Item) error {
+		return r.modifyLocked(models.RevisionDelete, item, false, trashItem)
+	})
+}
+
+// batch applies write to every item under the lock, restoring the previous
+// contents when one fails
+func (r *MemoryItemRepository) batch(items []*models.Item, write func(item *models.Item) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Revision slices are only appended to, so copying the maps is enough
+	savedItems, savedRevisions, savedNextID := maps.Clone(r.items), maps.Clone(r.revisions), r.nextID
+	for i, item := range items {
+		if err := write(item); err != nil {
+			r.items, r.revisions, r.nextID = savedItems, savedRevisions, savedNextID
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// record appends a revision for a change to an item. The caller holds the lock.
+func (r *MemoryItemRepository) record(action models.RevisionAction, before *models.Item, after models.Item) {
+	rev := models.ItemRevision{
+		ItemID:    after.ID,
+		Revision:  after.Version,
+		Action:    action,
+		ActorID:   &after.OwnerID,
+		After:     after.State(),
+		CreatedAt: time.Now(),
+	}
+	if before != nil {
+		state := before.State()
+		rev.Before = &state
+	}
+	r.revisions[after.ID] = append(r.revisions[after.ID], rev)
+}
+
+// ListRevisions implements ItemRepository
+func (r *MemoryItemRepository) ListRevisions(ctx context.Context, ownerID, itemID, beforeRevision, limit int) ([]models.ItemRevision, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if item, ok := r.items[itemID]; !ok || item.OwnerID != ownerID {
+		return nil, false, ErrNotFound
+	}
+
+	// Revisions are stored oldest first
+	revisions := []models.ItemRevision{}
+	all := r.revisions[itemID]
+	for i := len(all) - 1; i >= 0 && len(revisions) <= limit; i-- {
+		if beforeRevision == 0 || all[i].Revision < beforeRevision {
+			revisions = append(revisions, all[i])
+		}
+	}
+
+	hasMore := len(revisions) > limit
+	if hasMore {
+		revisions = revisions[:limit]
+	}
+
+	return revisions, hasMore, nil
+}
+
+// GetRevision implements ItemRepository
+func (r *MemoryItemRepository) GetRevision(ctx context.Context, ownerID, itemID, revision int) (models.ItemRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if item, ok := r.items[itemID]; !ok || item.OwnerID != ownerID {
+		return models.ItemRevision{}, ErrNotFound
+	}
+	for _, rev := range r.revisions[itemID] {
+		if rev.Revision == revision {
+			return rev, nil
+		}
+	}
+	return models.ItemRevision{}, ErrNotFound
+}
+
+// DeletePermanently implements ItemRepository
+func (r *MemoryItemRepository) DeletePermanently(ctx context.Context, ownerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || (ownerID != 0 && item.OwnerID != ownerID) || item.DeletedAt == nil {
+		return ErrNotFound
+	}
+	delete(r.items, id)
+	delete(r.revisions, id)
+	return nil
+}
+
+// PurgeTrash implements ItemRepository
+func (r *MemoryItemRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, item := range r.items {
+		if item.DeletedAt != nil && item.DeletedAt.Before(before) {
+			delete(r.items, id)
+			delete(r.revisions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// matchesListOptions applies the name prefix, label selector, attribute and
+// time range filters
+func matchesListOptions(item models.Item, opts ItemListOptions) bool {
+	if !opts.LabelSelector.Matches(item.Labels) {
+		return false
+	}
+	for _, filter := range opts.AttributeFilters {
+		if !filter.Matches(item.Attributes) {
+			return false
+		}
+	}
+	if opts.NamePrefix != "" && !strings.HasPrefix(item.Name, opts.NamePrefix) {
+		return false
+	}
+	if opts.CreatedAfter != nil && item.CreatedAt.Before(*opts.CreatedAfter) {
+		return false
+	}
+	if opts.CreatedBefore != nil && !item.CreatedAt.Before(*opts.CreatedBefore) {
+		return false
+	}
+	if opts.UpdatedAfter != nil && item.UpdatedAt.Before(*opts.UpdatedAfter) {
+		return false
+	}
+	if opts.UpdatedBefore != nil && !item.UpdatedAt.Before(*opts.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// sortKey returns the value an item is sorted by for the given field
+func sortKey(item models.Item, field string) interface{} {
+	switch field {
+	case "name":
+		return item.Name
+	case "updated_at":
+		return item.UpdatedAt
+	case "deleted_at":
+		if item.DeletedAt != nil {
+			return *item.DeletedAt
+		}
+		return time.Time{}
+	default:
+		return item.CreatedAt
+	}
+}
+
+// compareSortKey compares an item's (sort key, id) tuple against another one,
+// returning -1, 0 or 1
+func compareSortKey(item models.Item, field string, value interface{}, id int) int {
+	c := 0
+	switch v := value.(type) {
+	case string:
+		c = strings.Compare(item.Name, v)
+	case time.Time:
+		c = sortKey(item, field).(time.Time).Compare(v)
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case item.ID < id:
+		return -1
+	case item.ID > id:
+		return 1
+	}
+	return 0
+}
+
+// MemoryUserRepository is an in-memory UserRepository for tests and local runs
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[int]models.User
+	nextID int
+}
+
+// NewMemoryUserRepository creates an empty MemoryUserRepository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:  map[int]models.User{},
+		nextID: 1,
+	}
+}
+
+// Create implements UserRepository
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrConflict
+		}
+	}
+
+	if user.Role == "" {
+		user.Role = models.DefaultRole
+	}
+	user.ID = r.nextID
+	user.CreatedAt = time.Now()
+	r.nextID++
+	r.users[user.ID] = *user
+	return nil
+}
+
+// GetByID implements UserRepository
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// GetByUsername implements UserRepository
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// List implements UserRepository
+func (r *MemoryUserRepository) List(ctx context.Context, afterID, limit int) ([]models.User, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []models.User{}
+	for _, user := range r.users {
+		if user.ID > afterID {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+
+	return users, hasMore, nil
+}
+
+// UpdateRole implements UserRepository
+func (r *MemoryUserRepository) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	r.users[id] = user
+	return nil
+}
+
+// Delete implements UserRepository
+func (r *MemoryUserRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kubernetes-api/internal/models"
+)
+
+// MemoryAPIKeyRepository is an in-memory APIKeyRepository for tests and local runs.
+// Owners are resolved through the given UserRepository.
+type MemoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[int]models.APIKey
+	nextID int
+	users  UserRepository
+}
+
+// NewMemoryAPIKeyRepository creates an empty MemoryAPIKeyRepository
+func NewMemoryAPIKeyRepository(users UserRepository) *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{
+		keys:   map[int]models.APIKey{},
+		nextID: 1,
+		users:  users,
+	}
+}
+
+// Create implements APIKeyRepository
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.Prefix == key.Prefix {
+			return ErrConflict
+		}
+	}
+
+	key.ID = r.nextID
+	key.CreatedAt = time.Now()
+	r.nextID++
+	r.keys[key.ID] = *key
+	return nil
+}
+
+// List implements APIKeyRepository
+func (r *MemoryAPIKeyRepository) List(ctx context.Context, userID int) ([]models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// Revoke implements APIKeyRepository
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, userID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.UserID != userID {
+		return ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		r.keys[id] = key
+	}
+	return nil
+}
+
+// GetByPrefix implements APIKeyRepository
+func (r *MemoryAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	r.mu.RLock()
+	var found *models.APIKey
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			found = &key
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if found == nil {
+		return models.APIKey{}, ErrNotFound
+	}
+
+	owner, err := r.users.GetByID(ctx, found.UserID)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	found.Owner = owner
+	return *found, nil
+}
+
+// Touch implements APIKeyRepository
+func (r *MemoryAPIKeyRepository) Touch(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	key.LastUsedAt = &now
+	r.keys[id] = key
+	return nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"kubernetes-api/internal/models"
+)
+
+// MemoryImportJobRepository is an in-memory ImportJobRepository for tests and local runs
+type MemoryImportJobRepository struct {
+	mu     sync.RWMutex
+	jobs   map[int]models.ImportJob
+	nextID int
+}
+
+// NewMemoryImportJobRepository creates an empty MemoryImportJobRepository
+func NewMemoryImportJobRepository() *MemoryImportJobRepository {
+	return &MemoryImportJobRepository{jobs: map[int]models.ImportJob{}, nextID: 1}
+}
+
+// Create implements ImportJobRepository
+func (r *MemoryImportJobRepository) Create(ctx context.Context, job *models.ImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.ID = r.nextID
+	job.CreatedAt = time.Now()
+	job.HeartbeatAt = job.CreatedAt
+	job.Errors = []models.ImportError{}
+	r.nextID++
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+// Get implements ImportJobRepository
+func (r *MemoryImportJobRepository) Get(ctx context.Context, ownerID, id int) (models.ImportJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	job, ok := r.jobs[id]
+	if !ok || job.OwnerID != ownerID {
+		return models.ImportJob{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// Update implements ImportJobRepository
+func (r *MemoryImportJobRepository) Update(ctx context.Context, job *models.ImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.jobs[job.ID]
+	if !ok || !unfinished(current) {
+		return ErrImportEnded
+	}
+	// The importer keeps appending to its slice of errors
+	stored := *job
+	stored.Errors = slices.Clone(job.Errors)
+	if stored.Errors == nil {
+		stored.Errors = []models.ImportError{}
+	}
+	stored.InstanceID, stored.HeartbeatAt = current.InstanceID, time.Now()
+	r.jobs[job.ID] = stored
+	return nil
+}
+
+// Heartbeat implements ImportJobRepository
+func (r *MemoryImportJobRepository) Heartbeat(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok || !unfinished(job) {
+		return ErrImportEnded
+	}
+	job.HeartbeatAt = time.Now()
+	r.jobs[id] = job
+	return nil
+}
+
+// FailAbandoned implements ImportJobRepository
+func (r *MemoryImportJobRepository) FailAbandoned(ctx context.Context, instanceID, message string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var failed int64
+	now := time.Now()
+	for id, job := range r.jobs {
+		expired := job.HeartbeatAt.Before(now.Add(-ImportJobLease))
+		if unfinished(job) && (expired || (instanceID != "" && job.InstanceID == instanceID)) {
+			job.Status, job.Error, job.FinishedAt = models.ImportFailed, message, &now
+			r.jobs[id] = job
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// unfinished reports whether the job is still queued or running
+func unfinished(job models.ImportJob) bool {
+	return job.Status == models.ImportQueued || job.Status == models.ImportRunning
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kubernetes-api/internal/models"
+)
+
+// MemoryAttributeSchemaRepository is an in-memory AttributeSchemaRepository for tests and local runs
+type MemoryAttributeSchemaRepository struct {
+	mu      sync.RWMutex
+	schemas map[string]models.AttributeSchema
+}
+
+// NewMemoryAttributeSchemaRepository creates an empty MemoryAttributeSchemaRepository
+func NewMemoryAttributeSchemaRepository() *MemoryAttributeSchemaRepository {
+	return &MemoryAttributeSchemaRepository{schemas: map[string]models.AttributeSchema{}}
+}
+
+// List implements AttributeSchemaRepository
+func (r *MemoryAttributeSchemaRepository) List(ctx context.Context) ([]models.AttributeSchema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schemas := []models.AttributeSchema{}
+	for _, schema := range r.schemas {
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Namespace < schemas[j].Namespace })
+	return schemas, nil
+}
+
+// Get implements AttributeSchemaRepository
+func (r *MemoryAttributeSchemaRepository) Get(ctx context.Context, namespace string) (models.AttributeSchema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.schemas[namespace]
+	if !ok {
+		return models.AttributeSchema{}, ErrNotFound
+	}
+	return schema, nil
+}
+
+// Put implements AttributeSchemaRepository
+func (r *MemoryAttributeSchemaRepository) Put(ctx context.Context, schema *models.AttributeSchema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	schema.CreatedAt = now
+	if existing, ok := r.schemas[schema.Namespace]; ok {
+		schema.CreatedAt = existing.CreatedAt
+	}
+	schema.UpdatedAt = now
+	r.schemas[schema.Namespace] = *schema
+	return nil
+}
+
+// Delete implements AttributeSchemaRepository
+func (r *MemoryAttributeSchemaRepository) Delete(ctx context.Context, namespace string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schemas[namespace]; !ok {
+		return ErrNotFound
+	}
+	delete(r.schemas, namespace)
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"kubernetes-api/internal/models"
+)
+
+// memoryRefreshToken is the in-memory record of a refresh token hash
+type memoryRefreshToken struct {
+	sessionID string
+	expiresAt time.Time
+	used      bool
+}
+
+// MemorySessionRepository is an in-memory SessionRepository for tests and local runs
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+	tokens   map[string]memoryRefreshToken
+}
+
+// NewMemorySessionRepository creates an empty MemorySessionRepository
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: map[string]models.Session{},
+		tokens:   map[string]memoryRefreshToken{},
+	}
+}
+
+// Create implements SessionRepository
+func (r *MemorySessionRepository) Create(ctx context.Context, session models.Session, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; ok {
+		return ErrConflict
+	}
+
+	session.CreatedAt = time.Now()
+	r.sessions[session.ID] = session
+	r.tokens[tokenHash] = memoryRefreshToken{sessionID: session.ID, expiresAt: expiresAt}
+	return nil
+}
+
+// Rotate implements SessionRepository
+func (r *MemorySessionRepository) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[oldHash]
+	if !ok {
+		return models.Session{}, ErrNotFound
+	}
+
+	session := r.sessions[token.sessionID]
+	if session.RevokedAt != nil {
+		return models.Session{}, ErrTokenInvalid
+	}
+
+	if token.used {
+		now := time.Now()
+		session.RevokedAt = &now
+		r.sessions[session.ID] = session
+		return models.Session{}, ErrTokenReused
+	}
+
+	if time.Now().After(token.expiresAt) {
+		return models.Session{}, ErrTokenInvalid
+	}
+
+	token.used = true
+	r.tokens[oldHash] = token
+	r.tokens[newHash] = memoryRefreshToken{sessionID: session.ID, expiresAt: expiresAt}
+	return session, nil
+}
+
+// RevokeByToken implements SessionRepository
+func (r *MemorySessionRepository) RevokeByToken(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return ErrNotFound
+	}
+
+	session := r.sessions[token.sessionID]
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		r.sessions[session.ID] = session
+	}
+	return nil
+}
+
+// IsRevoked implements SessionRepository. Unknown sessions count as revoked.
+func (r *MemorySessionRepository) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	return !ok || session.RevokedAt != nil, nil
+}
@@ -0,0 +1,708 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/labels"
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres SQLSTATE for unique constraint violations
+const uniqueViolation = "23505"
+
+// itemColumns is the column list matching scanItem
+const itemColumns = "id, owner_id, external_id, name, description, labels, attributes, version, created_at, updated_at, deleted_at"
+
+// PostgresItemRepository is an ItemRepository backed by Postgres
+type PostgresItemRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresItemRepository creates a new PostgresItemRepository
+func NewPostgresItemRepository(db *sql.DB) *PostgresItemRepository {
+	return &PostgresItemRepository{db: db}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanItem reads a row selected with itemColumns followed by extra destinations
+func scanItem(row scanner, item *models.Item, extra ...interface{}) error {
+	var externalID, description sql.NullString
+	var itemLabels, itemAttributes []byte
+	var deletedAt sql.NullTime
+	dest := append([]interface{}{
+		&item.ID, &item.OwnerID, &externalID, &item.Name, &description, &itemLabels, &itemAttributes, &item.Version, &item.CreatedAt, &item.UpdatedAt, &deletedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	item.ExternalID = externalID.String
+	item.Description = description.String
+	item.Labels = map[string]string{}
+	if err := json.Unmarshal(itemLabels, &item.Labels); err != nil {
+		return err
+	}
+	item.Attributes = map[string]interface{}{}
+	if err := json.Unmarshal(itemAttributes, &item.Attributes); err != nil {
+		return err
+	}
+	item.DeletedAt = nil
+	if deletedAt.Valid {
+		item.DeletedAt = &deletedAt.Time
+	}
+	return nil
+}
+
+// objectParam encodes a map for a JSONB parameter, with nil as an empty
+// object. lib/pq sends []byte as bytea, so the JSON is passed as a string.
+func objectParam(m interface{}) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil || string(data) == "null" {
+		return "{}", err
+	}
+	return string(data), nil
+}
+
+// List implements ItemRepository
+func (r *PostgresItemRepository) List(ctx context.Context, opts ItemListOptions) ([]models.Item, bool, error) {
+	query, args := buildListQuery(opts)
+
+	items := []models.Item{}
+	err := metrics.TrackDatabaseOperation(ctx, "get_items", func() error {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var item models.Item
+			if err := scanItem(rows, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Trim the look-ahead row
+	hasMore := len(items) > opts.Limit
+	if hasMore {
+		items = items[:opts.Limit]
+	}
+
+	return items, hasMore, nil
+}
+
+// Search implements ItemRepository
+func (r *PostgresItemRepository) Search(ctx context.Context, opts ItemListOptions) ([]models.ItemSearchResult, bool, error) {
+	query, args := buildListQuery(opts)
+
+	results := []models.ItemSearchResult{}
+	err := metrics.TrackDatabaseOperation(ctx, "search_items", func() error {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var result models.ItemSearchResult
+			err := scanItem(rows, &result.Item, &result.Rank, &result.Highlights.Name, &result.Highlights.Description)
+			if err != nil {
+				return err
+			}
+			result.Highlights.Name = markHeadline(result.Highlights.Name)
+			result.Highlights.Description = markHeadline(result.Highlights.Description)
+			results = append(results, result)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > opts.Limit
+	if hasMore {
+		results = results[:opts.Limit]
+	}
+
+	return results, hasMore, nil
+}
+
+// Export implements ItemRepository. lib/pq reads rows off the connection as
+// they are scanned, so memory use does not grow with the number of items.
+func (r *PostgresItemRepository) Export(ctx context.Context, opts ItemListOptions, fn func(models.Item) error) error {
+	opts.Limit = 0
+	query, args := buildListQuery(opts)
+
+	return metrics.TrackDatabaseOperation(ctx, "export_items", func() error {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var item models.Item
+			if err := scanItem(rows, &item); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+// Get implements ItemRepository
+func (r *PostgresItemRepository) Get(ctx context.Context, ownerID, id int) (models.Item, error) {
+	var item models.Item
+	err := metrics.TrackDatabaseOperation(ctx, "get_item", func() error {
+		return scanItem(r.db.QueryRowContext(ctx,
+			"SELECT "+itemColumns+" FROM items WHERE id = $1 AND owner_id = $2 AND deleted_at IS NULL",
+			id, ownerID,
+		), &item)
+	})
+	return item, translateError(err)
+}
+
+// GetByExternalID implements ItemRepository
+func (r *PostgresItemRepository) GetByExternalID(ctx context.Context, ownerID int, externalID string) (models.Item, error) {
+	var item models.Item
+	err := metrics.TrackDatabaseOperation(ctx, "get_item_by_external_id", func() error {
+		return scanItem(r.db.QueryRowContext(ctx,
+			"SELECT "+itemColumns+" FROM items WHERE owner_id = $1 AND external_id = $2",
+			ownerID, externalID,
+		), &item)
+	})
+	return item, translateError(err)
+}
+
+// Create implements ItemRepository
+func (r *PostgresItemRepository) Create(ctx context.Context, item *models.Item) error {
+	err := metrics.TrackDatabaseOperation(ctx, "create_item", func() error {
+		return withTx(ctx, r.db, func(tx *sql.Tx) error {
+			return createItem(ctx, tx, item)
+		})
+	})
+	return translateError(err)
+}
+
+// createItem inserts an item and its first revision in tx
+func createItem(ctx context.Context, tx *sql.Tx, item *models.Item) error {
+	itemLabels, err := objectParam(item.Labels)
+	if err != nil {
+		return err
+	}
+	itemAttributes, err := objectParam(item.Attributes)
+	if err != nil {
+		return err
+	}
+
+	err = scanItem(tx.QueryRowContext(ctx,
+		"INSERT INTO items (owner_id, external_id, name, description, labels, attributes) VALUES ($1, NULLIF($2, ''), $3, $4, $5::jsonb, $6::jsonb) RETURNING "+itemColumns,
+		item.OwnerID, item.ExternalID, item.Name, item.Description, itemLabels, itemAttributes,
+	), item)
+	if err != nil {
+		return err
+	}
+	return insertRevision(ctx, tx, models.RevisionCreate, nil, *item)
+}
+
+// Update implements ItemRepository
+func (r *PostgresItemRepository) Update(ctx context.Context, item *models.Item) error {
+	update := *item
+	return r.modify(ctx, "update_item", models.RevisionUpdate, item, false, func(tx *sql.Tx, current *models.Item) error {
+		applyUpdate(current, update)
+		return nil
+	})
+}
+
+// Patch implements ItemRepository
+func (r *PostgresItemRepository) Patch(ctx context.Context, item *models.Item, apply func(*models.Item) error) error {
+	return r.modify(ctx, "patch_item", models.RevisionUpdate, item, false, func(tx *sql.Tx, current *models.Item) error {
+		return apply(current)
+	})
+}
+
+// Delete implements ItemRepository
+func (r *PostgresItemRepository) Delete(ctx context.Context, ownerID, id, version int) error {
+	item := models.Item{ID: id, OwnerID: ownerID, Version: version}
+	return r.modify(ctx, "delete_item", models.RevisionDelete, &item, false, trash)
+}
+
+// trash is the change Delete applies
+func trash(tx *sql.Tx, current *models.Item) error {
+	now := time.Now()
+	current.DeletedAt = &now
+	return nil
+}
+
+// Restore implements ItemRepository
+func (r *PostgresItemRepository) Restore(ctx context.Context, ownerID, id int) (models.Item, error) {
+	item := models.Item{ID: id, OwnerID: ownerID}
+	err := r.modify(ctx, "restore_item", models.RevisionRestore, &item, true, func(tx *sql.Tx, current *models.Item) error {
+		current.DeletedAt = nil
+		return nil
+	})
+	return item, err
+}
+
+// Revert implements ItemRepository
+func (r *PostgresItemRepository) Revert(ctx context.Context, item *models.Item, revision int) error {
+	return r.modify(ctx, "revert_item", models.RevisionRevert, item, false, func(tx *sql.Tx, current *models.Item) error {
+		var old models.ItemRevision
+		err := scanRevision(tx.QueryRowContext(ctx,
+			"SELECT "+revisionColumns+" FROM item_revisions WHERE item_id = $1 AND revision = $2",
+			current.ID, revision,
+		), &old)
+		if err != nil {
+			return err
+		}
+		current.Name, current.Description = old.After.Name, old.After.Description
+		current.Labels, current.Attributes = old.After.Labels, old.After.Attributes
+		return nil
+	})
+}
+
+// modify locks an item, lets change edit it and writes it back as the next
+// version, recording the change as a revision, all in one transaction. It
+// works on live items, or on items in the trash when trashed is set.
+func (r *PostgresItemRepository) modify(ctx context.Context, operation string, action models.RevisionAction, item *models.Item, trashed bool, change func(tx *sql.Tx, current *models.Item) error) error {
+	err := metrics.TrackDatabaseOperation(ctx, operation, func() error {
+		return withTx(ctx, r.db, func(tx *sql.Tx) error {
+			return modifyItem(ctx, tx, action, item, trashed, change)
+		})
+	})
+	return translateError(err)
+}
+
+// modifyItem is modify within tx
+func modifyItem(ctx context.Context, tx *sql.Tx, action models.RevisionAction, item *models.Item, trashed bool, change func(tx *sql.Tx, current *models.Item) error) error {
+	// Lock the row so concurrent writers queue behind this one
+	var current models.Item
+	err := scanItem(tx.QueryRowContext(ctx,
+		"SELECT "+itemColumns+" FROM items WHERE id = $1 AND owner_id = $2 AND (deleted_at IS NOT NULL) = $3 FOR UPDATE",
+		item.ID, item.OwnerID, trashed,
+	), &current)
+	if err != nil {
+		return err
+	}
+	if item.Version != 0 && item.Version != current.Version {
+		return ErrVersionMismatch
+	}
+
+	before := current
+	if err := change(tx, &current); err != nil {
+		return err
+	}
+	itemLabels, err := objectParam(current.Labels)
+	if err != nil {
+		return err
+	}
+	itemAttributes, err := objectParam(current.Attributes)
+	if err != nil {
+		return err
+	}
+
+	// Only content changes count as updates; moving to and from the trash does not
+	touch := action == models.RevisionUpdate || action == models.RevisionRevert
+	err = scanItem(tx.QueryRowContext(ctx, `
+		UPDATE items SET name = $1, description = $2, labels = $3::jsonb, attributes = $4::jsonb,
+			deleted_at = CASE WHEN $5 THEN COALESCE(deleted_at, NOW()) END,
+			updated_at = CASE WHEN $6 THEN NOW() ELSE updated_at END,
+			version = version + 1
+		WHERE id = $7
+		RETURNING `+itemColumns,
+		current.Name, current.Description, itemLabels, itemAttributes, current.DeletedAt != nil, touch, current.ID,
+	), item)
+	if err != nil {
+		return err
+	}
+	return insertRevision(ctx, tx, action, &before, *item)
+}
+
+// CreateBatch implements ItemRepository
+func (r *PostgresItemRepository) CreateBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(ctx, "batch_create_items", items, func(tx *sql.Tx, item *models.Item) error {
+		return createItem(ctx, tx, item)
+	})
+}
+
+// UpdateBatch implements ItemRepository
+func (r *PostgresItemRepository) UpdateBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(ctx, "batch_update_items", items, func(tx *sql.Tx, item *models.Item) error {
+		update := *item
+		return modifyItem(ctx, tx, models.RevisionUpdate, item, false, func(tx *sql.Tx, current *models.Item) error {
+			applyUpdate(current, update)
+			return nil
+		})
+	})
+}
+
+// DeleteBatch implements ItemRepository
+func (r *PostgresItemRepository) DeleteBatch(ctx context.Context, items []*models.Item) error {
+	return r.batch(ctx, "batch_delete_items", items, func(tx *sql.Tx, item *models.Item) error {
+		return modifyItem(ctx, tx, models.RevisionDelete, item, false, trash)
+	})
+}
+
+// batch applies write to every item in one transaction, wrapping the first
+// failure in a *BatchError
+func (r *PostgresItemRepository) batch(ctx context.Context, operation string, items []*models.Item, write func(tx *sql.Tx, item *models.Item) error) error {
+	err := metrics.TrackDatabaseOperation(ctx, operation, func() error {
+		return withTx(ctx, r.db, func(tx *sql.Tx) error {
+			for i, item := range items {
+				if err := write(tx, item); err != nil {
+					return &BatchError{Index: i, Err: err}
+				}
+			}
+			return nil
+		})
+	})
+	return translateError(err)
+}
+
+// DeletePermanently implements ItemRepository
+func (r *PostgresItemRepository) DeletePermanently(ctx context.Context, ownerID, id int) error {
+	err := metrics.TrackDatabaseOperation(ctx, "delete_item_permanently", func() error {
+		return expectRows(r.db.ExecContext(ctx,
+			"DELETE FROM items WHERE id = $1 AND ($2 = 0 OR owner_id = $2) AND deleted_at IS NOT NULL",
+			id, ownerID,
+		))
+	})
+	return translateError(err)
+}
+
+// PurgeTrash implements ItemRepository
+func (r *PostgresItemRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := metrics.TrackDatabaseOperation(ctx, "purge_items", func() error {
+		result, err := r.db.ExecContext(ctx, "DELETE FROM items WHERE deleted_at < $1", before)
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	return purged, err
+}
+
+// escapeLike escapes LIKE wildcards so a prefix is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Options passed to ts_headline for the highlights of search results. Matches
+// are delimited by private use characters, which are stripped from the text
+// beforehand, and turned into <mark> tags once the text has been escaped.
+const (
+	headlineStart              = "\ue000"
+	headlineStop               = "\ue001"
+	nameHeadlineOptions        = `StartSel="` + headlineStart + `", StopSel="` + headlineStop + `", HighlightAll=true`
+	descriptionHeadlineOptions = `StartSel="` + headlineStart + `", StopSel="` + headlineStop + `", MaxWords=35, MinWords=15, MaxFragments=2`
+)
+
+// headlineMarks turns the delimiters into <mark> tags
+var headlineMarks = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>")
+
+// markHeadline HTML-escapes a ts_headline result and marks its matches
+func markHeadline(headline string) string {
+	return headlineMarks.Replace(html.EscapeString(headline))
+}
+
+// tsQuery builds a to_tsquery expression matching every search term as a prefix
+func tsQuery(search string) string {
+	terms := SearchTerms(search)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// labelCondition translates a selector requirement into a condition on the
+// labels column. Equality uses containment so the GIN index applies.
+func labelCondition(req labels.Requirement, addArg func(interface{}) string) string {
+	switch req.Operator {
+	case labels.Equals, labels.NotEquals:
+		// Keys and values are validated, so they need no JSON escaping
+		contains := fmt.Sprintf("labels @> %s::jsonb", addArg(fmt.Sprintf(`{%q: %q}`, req.Key, req.Values[0])))
+		if req.Operator == labels.NotEquals {
+			return "NOT (" + contains + ")"
+		}
+		return contains
+	case labels.In:
+		return fmt.Sprintf("labels->>%s = ANY(%s)", addArg(req.Key), addArg(pq.Array(req.Values)))
+	case labels.NotIn:
+		key := addArg(req.Key)
+		return fmt.Sprintf("(labels->>%s IS NULL OR labels->>%s <> ALL(%s))", key, key, addArg(pq.Array(req.Values)))
+	case labels.Exists:
+		return "labels ? " + addArg(req.Key)
+	default:
+		return "NOT (labels ? " + addArg(req.Key) + ")"
+	}
+}
+
+// attributeCondition translates an attribute filter into a condition on the
+// attributes column. Equality uses containment so the GIN index applies.
+// Ordered comparisons use an SQL/JSON path, which only matches values of the
+// same type. The index cannot serve them: jsonb_path_ops only indexes
+// equality, so they and != filter the rows the other conditions select.
+func attributeCondition(filter attributes.Filter, addArg func(interface{}) string) string {
+	if filter.Operator.Ordered() {
+		vars, _ := json.Marshal(map[string]interface{}{"v": filter.Value})
+		return fmt.Sprintf("jsonb_path_exists(attributes, %s::jsonpath, %s::jsonb)", addArg(filter.JSONPath()), addArg(string(vars)))
+	}
+
+	// Filter values are scalars, so they always encode
+	doc, _ := filter.Containment()
+	contains := fmt.Sprintf("attributes @> %s::jsonb", addArg(string(doc)))
+	if filter.Operator == attributes.NotEquals {
+		return "NOT (" + contains + ")"
+	}
+	return contains
+}
+
+// buildListQuery builds the keyset-paginated SELECT for a listing. Searches
+// also select the rank and highlights.
+// One extra row is requested so the caller can tell whether another page
+// exists; a zero limit selects every row.
+func buildListQuery(opts ItemListOptions) (string, []interface{}) {
+	column := opts.SortField
+	if !opts.sortFields()[column] {
+		column = "created_at"
+	}
+
+	conditions := []string{"owner_id = $1", "deleted_at IS NULL"}
+	if opts.Trashed {
+		conditions[1] = "deleted_at IS NOT NULL"
+	}
+	args := []interface{}{opts.OwnerID}
+
+	addArg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if opts.NamePrefix != "" {
+		conditions = append(conditions, fmt.Sprintf(`name LIKE %s ESCAPE '\'`, addArg(escapeLike(opts.NamePrefix)+"%")))
+	}
+	if opts.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= "+addArg(*opts.CreatedAfter))
+	}
+	if opts.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < "+addArg(*opts.CreatedBefore))
+	}
+	if opts.UpdatedAfter != nil {
+		conditions = append(conditions, "updated_at >= "+addArg(*opts.UpdatedAfter))
+	}
+	if opts.UpdatedBefore != nil {
+		conditions = append(conditions, "updated_at < "+addArg(*opts.UpdatedBefore))
+	}
+
+	for _, req := range opts.LabelSelector {
+		conditions = append(conditions, labelCondition(req, addArg))
+	}
+	for _, filter := range opts.AttributeFilters {
+		conditions = append(conditions, attributeCondition(filter, addArg))
+	}
+
+	columns := itemColumns
+	if opts.Search != "" {
+		query := fmt.Sprintf("to_tsquery('english', %s)", addArg(tsQuery(opts.Search)))
+		rank := fmt.Sprintf("ts_rank_cd(search_vector, %s)::float8", query)
+		conditions = append(conditions, "search_vector @@ "+query)
+		strip := addArg(headlineStart + headlineStop)
+		columns += fmt.Sprintf(", %s, ts_headline('english', translate(name, %s, ''), %s, %s), ts_headline('english', translate(COALESCE(description, ''), %s, ''), %s, %s)",
+			rank, strip, query, addArg(nameHeadlineOptions), strip, query, addArg(descriptionHeadlineOptions))
+		if column == "relevance" {
+			column = rank
+		}
+	}
+
+	if opts.After != nil {
+		op := ">"
+		if opts.SortDesc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, addArg(opts.After.Value), addArg(opts.After.ID)))
+	}
+
+	direction := "ASC"
+	if opts.SortDesc {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM items WHERE %s ORDER BY %s %s, id %s",
+		columns, strings.Join(conditions, " AND "), column, direction, direction,
+	)
+	if opts.Limit > 0 {
+		query += " LIMIT " + addArg(opts.Limit+1)
+	}
+	return query, args
+}
+
+// PostgresUserRepository is a UserRepository backed by Postgres
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserRepository creates a new PostgresUserRepository
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+// userColumns is the column list matching scanUser
+const userColumns = "id, username, password_hash, email, role, created_at"
+
+// scanUser reads a row selected with userColumns
+func scanUser(row scanner, user *models.User) error {
+	return row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email, &user.Role, &user.CreatedAt)
+}
+
+// Create implements UserRepository
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.Role == "" {
+		user.Role = models.DefaultRole
+	}
+	err := metrics.TrackDatabaseOperation(ctx, "create_user", func() error {
+		return r.db.QueryRowContext(ctx,
+			"INSERT INTO users (username, password_hash, email, role) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+			user.Username, user.PasswordHash, user.Email, user.Role,
+		).Scan(&user.ID, &user.CreatedAt)
+	})
+	return translateError(err)
+}
+
+// GetByID implements UserRepository
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id int) (models.User, error) {
+	return r.getUser(ctx, "id", id)
+}
+
+// GetByUsername implements UserRepository
+func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.getUser(ctx, "username", username)
+}
+
+// getUser loads a single user by a unique column
+func (r *PostgresUserRepository) getUser(ctx context.Context, column string, value interface{}) (models.User, error) {
+	var user models.User
+	err := metrics.TrackDatabaseOperation(ctx, "get_user", func() error {
+		return scanUser(r.db.QueryRowContext(ctx,
+			"SELECT "+userColumns+" FROM users WHERE "+column+" = $1",
+			value,
+		), &user)
+	})
+	return user, translateError(err)
+}
+
+// List implements UserRepository
+func (r *PostgresUserRepository) List(ctx context.Context, afterID, limit int) ([]models.User, bool, error) {
+	users := []models.User{}
+	err := metrics.TrackDatabaseOperation(ctx, "get_users", func() error {
+		rows, err := r.db.QueryContext(ctx,
+			"SELECT "+userColumns+" FROM users WHERE id > $1 ORDER BY id LIMIT $2",
+			afterID, limit+1,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var user models.User
+			if err := scanUser(rows, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+
+	return users, hasMore, nil
+}
+
+// UpdateRole implements UserRepository
+func (r *PostgresUserRepository) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	err := metrics.TrackDatabaseOperation(ctx, "update_user_role", func() error {
+		return expectRows(r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id))
+	})
+	return translateError(err)
+}
+
+// Delete implements UserRepository
+func (r *PostgresUserRepository) Delete(ctx context.Context, id int) error {
+	err := metrics.TrackDatabaseOperation(ctx, "delete_user", func() error {
+		return expectRows(r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id))
+	})
+	return translateError(err)
+}
+
+// expectRows turns an Exec result that touched no rows into sql.ErrNoRows
+func expectRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// translateError maps driver errors onto the repository's domain errors
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return &BatchError{Index: batchErr.Index, Err: translateError(batchErr.Err)}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && string(pqErr.Code) == uniqueViolation {
+		return fmt.Errorf("%w: %s", ErrConflict, pqErr.Detail)
+	}
+	return err
+}
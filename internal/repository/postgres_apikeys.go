@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// apiKeyColumns is the column list matching scanAPIKey
+const apiKeyColumns = "k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.created_at, k.last_used_at, k.revoked_at"
+
+// PostgresAPIKeyRepository is an APIKeyRepository backed by Postgres
+type PostgresAPIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresAPIKeyRepository creates a new PostgresAPIKeyRepository
+func NewPostgresAPIKeyRepository(db *sql.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+// scanAPIKey reads a row selected with apiKeyColumns followed by extra destinations
+func scanAPIKey(row scanner, key *models.APIKey, extra ...interface{}) error {
+	var scopes []string
+	dest := append([]interface{}{
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&scopes),
+		&key.ExpiresAt, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	key.Scopes = make([]models.Scope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = models.Scope(scope)
+	}
+	return nil
+}
+
+// Create implements APIKeyRepository
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	err := metrics.TrackDatabaseOperation(ctx, "create_api_key", func() error {
+		return r.db.QueryRowContext(ctx,
+			"INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+			key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(scopes), key.ExpiresAt,
+		).Scan(&key.ID, &key.CreatedAt)
+	})
+	return translateError(err)
+}
+
+// List implements APIKeyRepository
+func (r *PostgresAPIKeyRepository) List(ctx context.Context, userID int) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := metrics.TrackDatabaseOperation(ctx, "get_api_keys", func() error {
+		rows, err := r.db.QueryContext(ctx,
+			"SELECT "+apiKeyColumns+" FROM api_keys k WHERE k.user_id = $1 ORDER BY k.id",
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var key models.APIKey
+			if err := scanAPIKey(rows, &key); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		return rows.Err()
+	})
+	return keys, err
+}
+
+// Revoke implements APIKeyRepository
+func (r *PostgresAPIKeyRepository) Revoke(ctx context.Context, userID, id int) error {
+	err := metrics.TrackDatabaseOperation(ctx, "revoke_api_key", func() error {
+		return expectRows(r.db.ExecContext(ctx,
+			"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND user_id = $2",
+			id, userID,
+		))
+	})
+	return translateError(err)
+}
+
+// GetByPrefix implements APIKeyRepository
+func (r *PostgresAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	var key models.APIKey
+	err := metrics.TrackDatabaseOperation(ctx, "get_api_key", func() error {
+		return scanAPIKey(r.db.QueryRowContext(ctx,
+			"SELECT "+apiKeyColumns+", u.username, u.role FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.prefix = $1",
+			prefix,
+		), &key, &key.Owner.Username, &key.Owner.Role)
+	})
+	key.Owner.ID = key.UserID
+	return key, translateError(err)
+}
+
+// Touch implements APIKeyRepository. Writes are throttled to once a minute per key.
+func (r *PostgresAPIKeyRepository) Touch(ctx context.Context, id int) error {
+	return metrics.TrackDatabaseOperation(ctx, "touch_api_key", func() error {
+		_, err := r.db.ExecContext(ctx,
+			"UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')",
+			id,
+		)
+		return err
+	})
+}
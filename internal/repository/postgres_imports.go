@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+)
+
+// importJobColumns is the column list matching scanImportJob
+const importJobColumns = "id, owner_id, status, format, filename, dry_run, bytes_total, bytes_read, rows_read, created, updated, unchanged, failed, errors, error, created_at, started_at, finished_at, instance_id, heartbeat_at"
+
+// PostgresImportJobRepository is an ImportJobRepository backed by Postgres
+type PostgresImportJobRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresImportJobRepository creates a new PostgresImportJobRepository
+func NewPostgresImportJobRepository(db *sql.DB) *PostgresImportJobRepository {
+	return &PostgresImportJobRepository{db: db}
+}
+
+// scanImportJob reads a row selected with importJobColumns
+func scanImportJob(row scanner, job *models.ImportJob) error {
+	var errs []byte
+	var jobError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.OwnerID, &job.Status, &job.Format, &job.Filename, &job.DryRun,
+		&job.BytesTotal, &job.BytesRead, &job.RowsRead, &job.Created, &job.Updated, &job.Unchanged, &job.Failed,
+		&errs, &jobError, &job.CreatedAt, &startedAt, &finishedAt, &job.InstanceID, &job.HeartbeatAt,
+	)
+	if err != nil {
+		return err
+	}
+	job.Errors = []models.ImportError{}
+	if err := json.Unmarshal(errs, &job.Errors); err != nil {
+		return err
+	}
+	job.Error = jobError.String
+	job.StartedAt, job.FinishedAt = nil, nil
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return nil
+}
+
+// Create implements ImportJobRepository
+func (r *PostgresImportJobRepository) Create(ctx context.Context, job *models.ImportJob) error {
+	err := metrics.TrackDatabaseOperation(ctx, "create_import_job", func() error {
+		return scanImportJob(r.db.QueryRowContext(ctx, `
+			INSERT INTO import_jobs (owner_id, status, format, filename, dry_run, bytes_total, instance_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+importJobColumns,
+			job.OwnerID, job.Status, job.Format, job.Filename, job.DryRun, job.BytesTotal, job.InstanceID,
+		), job)
+	})
+	return translateError(err)
+}
+
+// Get implements ImportJobRepository
+func (r *PostgresImportJobRepository) Get(ctx context.Context, ownerID, id int) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := metrics.TrackDatabaseOperation(ctx, "get_import_job", func() error {
+		return scanImportJob(r.db.QueryRowContext(ctx,
+			"SELECT "+importJobColumns+" FROM import_jobs WHERE id = $1 AND owner_id = $2",
+			id, ownerID,
+		), &job)
+	})
+	return job, translateError(err)
+}
+
+// Update implements ImportJobRepository
+func (r *PostgresImportJobRepository) Update(ctx context.Context, job *models.ImportJob) error {
+	errs, err := json.Marshal(job.Errors)
+	if err != nil || job.Errors == nil {
+		errs = []byte("[]")
+	}
+
+	err = metrics.TrackDatabaseOperation(ctx, "update_import_job", func() error {
+		// lib/pq sends []byte as bytea, so the errors are passed as a string
+		return expectRows(r.db.ExecContext(ctx, `
+			UPDATE import_jobs
+			SET status = $1, bytes_read = $2, rows_read = $3, created = $4, updated = $5, unchanged = $6, failed = $7,
+				errors = $8::jsonb, error = NULLIF($9, ''), started_at = $10, finished_at = $11, heartbeat_at = NOW()
+			WHERE id = $12 AND status IN ($13, $14)`,
+			job.Status, job.BytesRead, job.RowsRead, job.Created, job.Updated, job.Unchanged, job.Failed,
+			string(errs), job.Error, job.StartedAt, job.FinishedAt, job.ID, models.ImportQueued, models.ImportRunning,
+		))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrImportEnded
+	}
+	return translateError(err)
+}
+
+// Heartbeat implements ImportJobRepository
+func (r *PostgresImportJobRepository) Heartbeat(ctx context.Context, id int) error {
+	err := metrics.TrackDatabaseOperation(ctx, "heartbeat_import_job", func() error {
+		return expectRows(r.db.ExecContext(ctx,
+			"UPDATE import_jobs SET heartbeat_at = NOW() WHERE id = $1 AND status IN ($2, $3)",
+			id, models.ImportQueued, models.ImportRunning,
+		))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrImportEnded
+	}
+	return translateError(err)
+}
+
+// FailAbandoned implements ImportJobRepository
+func (r *PostgresImportJobRepository) FailAbandoned(ctx context.Context, instanceID, message string) (int64, error) {
+	var failed int64
+	err := metrics.TrackDatabaseOperation(ctx, "fail_abandoned_import_jobs", func() error {
+		result, err := r.db.ExecContext(ctx, `
+			UPDATE import_jobs SET status = $1, error = $2, finished_at = NOW()
+			WHERE status IN ($3, $4)
+				AND (heartbeat_at < NOW() - make_interval(secs => $5) OR ($6::text <> '' AND instance_id = $6))`,
+			models.ImportFailed, message, models.ImportQueued, models.ImportRunning, ImportJobLease.Seconds(), instanceID,
+		)
+		if err != nil {
+			return err
+		}
+		failed, err = result.RowsAffected()
+		return err
+	})
+	return failed, translateError(err)
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+)
+
+// revisionColumns is the column list matching scanRevision
+const revisionColumns = "item_id, revision, action, actor_id, before_state, after_state, created_at"
+
+// scanRevision reads a row selected with revisionColumns
+func scanRevision(row scanner, rev *models.ItemRevision) error {
+	var actorID sql.NullInt64
+	var before, after []byte
+	if err := row.Scan(&rev.ItemID, &rev.Revision, &rev.Action, &actorID, &before, &after, &rev.CreatedAt); err != nil {
+		return err
+	}
+
+	rev.ActorID = nil
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		rev.ActorID = &id
+	}
+	rev.Before = nil
+	if before != nil {
+		rev.Before = &models.ItemState{}
+		if err := json.Unmarshal(before, rev.Before); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(after, &rev.After)
+}
+
+// insertRevision records a change to an item made in tx. before is nil for
+// newly created items.
+func insertRevision(ctx context.Context, tx *sql.Tx, action models.RevisionAction, before *models.Item, after models.Item) error {
+	// lib/pq sends []byte as bytea, so the states are passed as strings
+	var beforeState interface{}
+	if before != nil {
+		data, err := json.Marshal(before.State())
+		if err != nil {
+			return err
+		}
+		beforeState = string(data)
+	}
+	afterState, err := json.Marshal(after.State())
+	if err != nil {
+		return err
+	}
+
+	// Items are only written by their owners
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO item_revisions (item_id, revision, action, actor_id, before_state, after_state) VALUES ($1, $2, $3, $4, $5, $6)",
+		after.ID, after.Version, action, after.OwnerID, beforeState, string(afterState),
+	)
+	return err
+}
+
+// ListRevisions implements ItemRepository
+func (r *PostgresItemRepository) ListRevisions(ctx context.Context, ownerID, itemID, beforeRevision, limit int) ([]models.ItemRevision, bool, error) {
+	revisions := []models.ItemRevision{}
+	err := metrics.TrackDatabaseOperation(ctx, "get_item_revisions", func() error {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT `+revisionColumns+` FROM item_revisions
+			WHERE item_id = $1 AND ($3 = 0 OR revision < $3)
+				AND EXISTS (SELECT 1 FROM items WHERE id = $1 AND owner_id = $2)
+			ORDER BY revision DESC LIMIT $4`,
+			itemID, ownerID, beforeRevision, limit+1,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var rev models.ItemRevision
+			if err := scanRevision(rows, &rev); err != nil {
+				return err
+			}
+			revisions = append(revisions, rev)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// An empty page is only valid for items the caller owns
+		if len(revisions) == 0 {
+			var exists bool
+			err := r.db.QueryRowContext(ctx,
+				"SELECT EXISTS (SELECT 1 FROM items WHERE id = $1 AND owner_id = $2)",
+				itemID, ownerID,
+			).Scan(&exists)
+			if err == nil && !exists {
+				return ErrNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(revisions) > limit
+	if hasMore {
+		revisions = revisions[:limit]
+	}
+
+	return revisions, hasMore, nil
+}
+
+// GetRevision implements ItemRepository
+func (r *PostgresItemRepository) GetRevision(ctx context.Context, ownerID, itemID, revision int) (models.ItemRevision, error) {
+	var rev models.ItemRevision
+	err := metrics.TrackDatabaseOperation(ctx, "get_item_revision", func() error {
+		return scanRevision(r.db.QueryRowContext(ctx, `
+			SELECT `+revisionColumns+` FROM item_revisions
+			WHERE item_id = $1 AND revision = $3
+				AND EXISTS (SELECT 1 FROM items WHERE id = $1 AND owner_id = $2)`,
+			itemID, ownerID, revision,
+		), &rev)
+	})
+	return rev, translateError(err)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+)
+
+// attributeSchemaColumns is the column list matching scanAttributeSchema
+const attributeSchemaColumns = "namespace, schema, created_at, updated_at"
+
+// PostgresAttributeSchemaRepository is an AttributeSchemaRepository backed by Postgres
+type PostgresAttributeSchemaRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresAttributeSchemaRepository creates a new PostgresAttributeSchemaRepository
+func NewPostgresAttributeSchemaRepository(db *sql.DB) *PostgresAttributeSchemaRepository {
+	return &PostgresAttributeSchemaRepository{db: db}
+}
+
+// scanAttributeSchema reads a row selected with attributeSchemaColumns
+func scanAttributeSchema(row scanner, schema *models.AttributeSchema) error {
+	var doc []byte
+	if err := row.Scan(&schema.Namespace, &doc, &schema.CreatedAt, &schema.UpdatedAt); err != nil {
+		return err
+	}
+	schema.Schema = doc
+	return nil
+}
+
+// List implements AttributeSchemaRepository
+func (r *PostgresAttributeSchemaRepository) List(ctx context.Context) ([]models.AttributeSchema, error) {
+	schemas := []models.AttributeSchema{}
+	err := metrics.TrackDatabaseOperation(ctx, "get_attribute_schemas", func() error {
+		rows, err := r.db.QueryContext(ctx, "SELECT "+attributeSchemaColumns+" FROM attribute_schemas ORDER BY namespace")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var schema models.AttributeSchema
+			if err := scanAttributeSchema(rows, &schema); err != nil {
+				return err
+			}
+			schemas = append(schemas, schema)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// Get implements AttributeSchemaRepository
+func (r *PostgresAttributeSchemaRepository) Get(ctx context.Context, namespace string) (models.AttributeSchema, error) {
+	var schema models.AttributeSchema
+	err := metrics.TrackDatabaseOperation(ctx, "get_attribute_schema", func() error {
+		return scanAttributeSchema(r.db.QueryRowContext(ctx,
+			"SELECT "+attributeSchemaColumns+" FROM attribute_schemas WHERE namespace = $1",
+			namespace,
+		), &schema)
+	})
+	return schema, translateError(err)
+}
+
+// Put implements AttributeSchemaRepository
+func (r *PostgresAttributeSchemaRepository) Put(ctx context.Context, schema *models.AttributeSchema) error {
+	err := metrics.TrackDatabaseOperation(ctx, "put_attribute_schema", func() error {
+		// lib/pq sends []byte as bytea, so the schema is passed as a string
+		return scanAttributeSchema(r.db.QueryRowContext(ctx, `
+			INSERT INTO attribute_schemas (namespace, schema) VALUES ($1, $2::jsonb)
+			ON CONFLICT (namespace) DO UPDATE SET schema = EXCLUDED.schema, updated_at = NOW()
+			RETURNING `+attributeSchemaColumns,
+			schema.Namespace, string(schema.Schema),
+		), schema)
+	})
+	return translateError(err)
+}
+
+// Delete implements AttributeSchemaRepository
+func (r *PostgresAttributeSchemaRepository) Delete(ctx context.Context, namespace string) error {
+	err := metrics.TrackDatabaseOperation(ctx, "delete_attribute_schema", func() error {
+		return expectRows(r.db.ExecContext(ctx, "DELETE FROM attribute_schemas WHERE namespace = $1", namespace))
+	})
+	return translateError(err)
+}
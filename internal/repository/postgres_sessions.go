@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"kubernetes-api/internal/logging"
+	"kubernetes-api/internal/metrics"
+	"kubernetes-api/internal/models"
+)
+
+// PostgresSessionRepository is a SessionRepository backed by Postgres
+type PostgresSessionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSessionRepository creates a new PostgresSessionRepository
+func NewPostgresSessionRepository(db *sql.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+// Create implements SessionRepository
+func (r *PostgresSessionRepository) Create(ctx context.Context, session models.Session, tokenHash string, expiresAt time.Time) error {
+	err := metrics.TrackDatabaseOperation(ctx, "create_session", func() error {
+		return withTx(ctx, r.db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO sessions (id, user_id) VALUES ($1, $2)",
+				session.ID, session.UserID,
+			); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)",
+				tokenHash, session.ID, expiresAt,
+			)
+			return err
+		})
+	})
+	return translateError(err)
+}
+
+// Rotate implements SessionRepository
+func (r *PostgresSessionRepository) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (models.Session, error) {
+	var session models.Session
+	reused := false
+
+	err := metrics.TrackDatabaseOperation(ctx, "rotate_refresh_token", func() error {
+		return withTx(ctx, r.db, func(tx *sql.Tx) error {
+			var tokenExpiresAt time.Time
+			var usedAt sql.NullTime
+			var revokedAt sql.NullTime
+
+			// Lock the token row so concurrent refreshes of the same token serialize
+			err := tx.QueryRowContext(ctx, `
+				SELECT s.id, s.user_id, s.created_at, s.revoked_at, t.expires_at, t.used_at
+				FROM refresh_tokens t JOIN sessions s ON s.id = t.session_id
+				WHERE t.token_hash = $1
+				FOR UPDATE OF t`,
+				oldHash,
+			).Scan(&session.ID, &session.UserID, &session.CreatedAt, &revokedAt, &tokenExpiresAt, &usedAt)
+			if err != nil {
+				return err
+			}
+
+			if revokedAt.Valid {
+				return ErrTokenInvalid
+			}
+
+			if usedAt.Valid {
+				// A consumed token was presented again: assume it leaked and kill the family
+				reused = true
+				_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1", session.ID)
+				return err
+			}
+
+			if time.Now().After(tokenExpiresAt) {
+				return ErrTokenInvalid
+			}
+
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1",
+				oldHash,
+			); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)",
+				newHash, session.ID, expiresAt,
+			)
+			return err
+		})
+	})
+	if err != nil {
+		return models.Session{}, translateError(err)
+	}
+	if reused {
+		logging.FromContext(ctx).Warnf("Refresh token reuse detected, revoked session %s for user %d", session.ID, session.UserID)
+		return models.Session{}, ErrTokenReused
+	}
+
+	return session, nil
+}
+
+// RevokeByToken implements SessionRepository
+func (r *PostgresSessionRepository) RevokeByToken(ctx context.Context, tokenHash string) error {
+	err := metrics.TrackDatabaseOperation(ctx, "revoke_session", func() error {
+		return expectRows(r.db.ExecContext(ctx, `
+			UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW())
+			WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)`,
+			tokenHash,
+		))
+	})
+	return translateError(err)
+}
+
+// IsRevoked implements SessionRepository. Unknown sessions count as revoked.
+func (r *PostgresSessionRepository) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var revokedAt sql.NullTime
+	err := metrics.TrackDatabaseOperation(ctx, "get_session", func() error {
+		return r.db.QueryRowContext(ctx,
+			"SELECT revoked_at FROM sessions WHERE id = $1",
+			sessionID,
+		).Scan(&revokedAt)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revokedAt.Valid, nil
+}
+
+// withTx runs f inside a transaction, committing on success and rolling back on error
+func withTx(ctx context.Context, db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logging.FromContext(ctx).WithError(rbErr).Warn("Failed to roll back transaction")
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
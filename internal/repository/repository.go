@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/labels"
+	"kubernetes-api/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist or is not visible to the caller
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a uniqueness constraint
+	ErrConflict = errors.New("conflict")
+	// ErrTokenReused is returned when an already rotated refresh token is presented again
+	ErrTokenReused = errors.New("refresh token reused")
+	// ErrTokenInvalid is returned for refresh tokens that are expired or belong to a revoked session
+	ErrTokenInvalid = errors.New("refresh token expired or revoked")
+	// ErrVersionMismatch is returned when a conditional write finds the record at a different version
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrImportEnded is returned when writing to an import job that has already completed or failed
+	ErrImportEnded = errors.New("import job already ended")
+)
+
+// BatchError reports the item of a batch that failed, rolling back the batch
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+// Error implements error
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the failed item
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// applyUpdate copies the content Update writes from update to current. Unset
+// labels and attributes are left as they are.
+func applyUpdate(current *models.Item, update models.Item) {
+	current.Name, current.Description = update.Name, update.Description
+	if update.Labels != nil {
+		current.Labels = update.Labels
+	}
+	if update.Attributes != nil {
+		current.Attributes = update.Attributes
+	}
+}
+
+// ItemSortFields whitelists the columns items can be sorted by
+var ItemSortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+}
+
+// TrashSortFields whitelists the columns trashed items can be sorted by
+var TrashSortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+	"deleted_at": true,
+}
+
+// SearchSortFields whitelists the sort keys of search results
+var SearchSortFields = map[string]bool{
+	"relevance":  true,
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+}
+
+// sortFields returns the sort whitelist for a listing
+func (opts ItemListOptions) sortFields() map[string]bool {
+	switch {
+	case opts.Search != "":
+		return SearchSortFields
+	case opts.Trashed:
+		return TrashSortFields
+	}
+	return ItemSortFields
+}
+
+// SearchTerms splits a search query into lowercase words. Everything but
+// letters and digits separates words, so queries carry no search operators.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ItemCursor is the keyset position of the last item of the previous page.
+// Value holds the sort key: a time.Time for timestamp sorts, a string for name.
+type ItemCursor struct {
+	Value interface{}
+	ID    int
+}
+
+// ItemListOptions controls filtering, sorting and pagination of item listings
+type ItemListOptions struct {
+	OwnerID       int
+	Limit         int
+	SortField     string
+	SortDesc      bool
+	After         *ItemCursor
+	NamePrefix    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// LabelSelector keeps the items whose labels match every requirement
+	LabelSelector labels.Selector
+	// AttributeFilters keeps the items whose attributes match every filter
+	AttributeFilters []attributes.Filter
+	// Trashed lists deleted items instead of live ones
+	Trashed bool
+	// Search holds the words to search for; only Search honors it. Every word
+	// must match, as a prefix, a word in the name or description.
+	Search string
+}
+
+// ItemRepository persists items. All lookups are scoped to the owning user.
+// Deleted items are kept in a trash, invisible to everything but List with
+// Trashed set, Restore, DeletePermanently and the revision history, until they
+// are purged. Every write is recorded as a revision numbered by the version it
+// produced.
+type ItemRepository interface {
+	// List returns up to opts.Limit items and whether more items follow
+	List(ctx context.Context, opts ItemListOptions) ([]models.Item, bool, error)
+	// Search is List for the live items matching opts.Search, with their rank
+	// and highlights. Results can be sorted by relevance.
+	Search(ctx context.Context, opts ItemListOptions) ([]models.ItemSearchResult, bool, error)
+	// Export calls fn with every item matching opts, in order, reading them
+	// from storage as it goes. opts.Limit is ignored. An error from fn stops the
+	// export and is returned unchanged.
+	Export(ctx context.Context, opts ItemListOptions, fn func(models.Item) error) error
+	Get(ctx context.Context, ownerID, id int) (models.Item, error)
+	// GetByExternalID returns the item with the external ID, even if it is in
+	// the trash
+	GetByExternalID(ctx context.Context, ownerID int, externalID string) (models.Item, error)
+	// Create stores the item and fills in its ID and timestamps. An external
+	// ID already used by another item of the owner is an ErrConflict.
+	Create(ctx context.Context, item *models.Item) error
+	// Update overwrites the name and description, and the labels and attributes
+	// when they are set, increments the version and refreshes the item from
+	// storage. When item.Version is set the update only applies to that
+	// version, otherwise ErrVersionMismatch is returned.
+	Update(ctx context.Context, item *models.Item) error
+	// Patch reads the item identified by item.ID and item.OwnerID, lets apply
+	// modify it and stores the result, all in one transaction. item.Version is
+	// honored like in Update, and errors from apply are returned unchanged.
+	Patch(ctx context.Context, item *models.Item, apply func(*models.Item) error) error
+	// Delete moves the item to the trash. A non-zero version makes the delete
+	// conditional like Update.
+	Delete(ctx context.Context, ownerID, id, version int) error
+	// Restore moves an item out of the trash
+	Restore(ctx context.Context, ownerID, id int) (models.Item, error)
+	// DeletePermanently removes an item in the trash. An ownerID of 0 matches
+	// items of any owner, for admins.
+	DeletePermanently(ctx context.Context, ownerID, id int) error
+	// PurgeTrash removes items deleted before the cutoff and returns how many
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+
+	// ListRevisions returns up to limit revisions of an item, newest first and
+	// older than beforeRevision when it is set, and whether more follow
+	ListRevisions(ctx context.Context, ownerID, itemID, beforeRevision, limit int) ([]models.ItemRevision, bool, error)
+	GetRevision(ctx context.Context, ownerID, itemID, revision int) (models.ItemRevision, error)
+	// Revert sets the name, description, labels and attributes back to those of
+	// an earlier revision, recorded as a new revision. item.Version is honored
+	// like in Update.
+	Revert(ctx context.Context, item *models.Item, revision int) error
+
+	// CreateBatch, UpdateBatch and DeleteBatch apply Create, Update and Delete
+	// to every item in one transaction. Deleted items are identified by ID,
+	// OwnerID and Version, and are filled in from storage. When one fails
+	// nothing is written and a *BatchError names it.
+	CreateBatch(ctx context.Context, items []*models.Item) error
+	UpdateBatch(ctx context.Context, items []*models.Item) error
+	DeleteBatch(ctx context.Context, items []*models.Item) error
+}
+
+// UserRepository persists user accounts
+type UserRepository interface {
+	// Create stores the user and fills in its ID and creation time
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	// List returns up to limit users with an ID greater than afterID and whether more follow
+	List(ctx context.Context, afterID, limit int) ([]models.User, bool, error)
+	UpdateRole(ctx context.Context, id int, role models.Role) error
+	Delete(ctx context.Context, id int) error
+}
+
+// SessionRepository persists login sessions and their rotating refresh tokens
+type SessionRepository interface {
+	// Create starts a session and stores its first refresh token hash
+	Create(ctx context.Context, session models.Session, tokenHash string, expiresAt time.Time) error
+	// Rotate consumes the refresh token identified by oldHash and stores newHash in
+	// its place. Presenting a consumed token revokes the whole session and
+	// returns ErrTokenReused.
+	Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (models.Session, error)
+	// RevokeByToken revokes the session the refresh token belongs to
+	RevokeByToken(ctx context.Context, tokenHash string) error
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// APIKeyRepository persists API keys
+type APIKeyRepository interface {
+	// Create stores the key and fills in its ID and creation time
+	Create(ctx context.Context, key *models.APIKey) error
+	List(ctx context.Context, userID int) ([]models.APIKey, error)
+	Revoke(ctx context.Context, userID, id int) error
+	// GetByPrefix returns the key with the given prefix together with its owner
+	GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	// Touch records that the key was just used
+	Touch(ctx context.Context, id int) error
+}
+
+// AttributeSchemaRepository persists the JSON Schemas of attribute namespaces
+type AttributeSchemaRepository interface {
+	// List returns every schema, ordered by namespace
+	List(ctx context.Context) ([]models.AttributeSchema, error)
+	Get(ctx context.Context, namespace string) (models.AttributeSchema, error)
+	// Put creates or replaces the schema of a namespace and fills in its timestamps
+	Put(ctx context.Context, schema *models.AttributeSchema) error
+	Delete(ctx context.Context, namespace string) error
+}
+
+// ImportJobRepository persists import jobs. Jobs are scoped to the user who
+// started them.
+type ImportJobRepository interface {
+	// Create stores the job and fills in its ID and creation time
+	Create(ctx context.Context, job *models.ImportJob) error
+	Get(ctx context.Context, ownerID, id int) (models.ImportJob, error)
+	// Update stores the status, progress, counts and errors of the job and
+	// renews its heartbeat. Jobs that have ended cannot change again; they
+	// give ErrImportEnded.
+	Update(ctx context.Context, job *models.ImportJob) error
+	// Heartbeat renews the lease of a queued or running job, or returns
+	// ErrImportEnded
+	Heartbeat(ctx context.Context, id int) error
+	// FailAbandoned marks the queued and running jobs whose lease expired as
+	// failed with the given error, along with those of instanceID when it is
+	// set, and returns how many
+	FailAbandoned(ctx context.Context, instanceID, message string) (int64, error)
+}
+
+// ImportJobLease is how long a queued or running import job stays claimed by
+// its instance after the last heartbeat
+const ImportJobLease = 2 * time.Minute
@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"kubernetes-api/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this service
+const instrumentationName = "kubernetes-api"
+
+// tracer creates the HTTP spans. It resolves through the global provider, so
+// it picks up the provider installed by Init.
+var tracer = otel.Tracer(instrumentationName)
+
+// Init installs the global tracer provider and W3C trace context propagation.
+// The exporter is selected with OTEL_TRACES_EXPORTER: "otlp" (configured with
+// the standard OTEL_EXPORTER_OTLP_* variables), "stdout" or "none" (default).
+// The returned function flushes and stops the exporter.
+func Init(ctx context.Context, serviceName, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	exporterName := utils.GetEnv("OTEL_TRACES_EXPORTER", "none")
+	switch exporterName {
+	case "none":
+		// Incoming trace IDs are still propagated into logs, but no spans are recorded
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override these defaults
+	res, err := resource.Merge(
+		resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version),
+		),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	// The sampler follows OTEL_TRACES_SAMPLER, defaulting to parent-based always-on
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	logrus.Infof("Tracing enabled with %s exporter", exporterName)
+	return provider.Shutdown, nil
+}
+
+// Middleware continues the trace from the incoming traceparent header and
+// wraps the request in a server span named after the mux route template. Like
+// metrics.MetricsMiddleware it also wraps the router's NotFound and
+// MethodNotAllowed handlers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+			),
+		)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// The span is completed even when the handler panics, e.g. to abort a
+		// streaming response
+		defer func() {
+			aborted := recover()
+
+			span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+			switch {
+			case aborted != nil:
+				span.SetStatus(codes.Error, "response aborted")
+			case recorder.status >= http.StatusInternalServerError:
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+			span.End()
+
+			if aborted != nil {
+				panic(aborted)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+	})
+}
+
+// StartDatabaseSpan starts a client span for a database operation
+func StartDatabaseSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", operation),
+		),
+	)
+}
+
+// EndSpan records err on the span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogHook adds the trace and span IDs to log entries created with
+// logrus.WithContext, so logs can be joined with traces
+type LogHook struct{}
+
+// Levels implements logrus.Hook
+func (LogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (LogHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	spanContext := trace.SpanContextFromContext(entry.Context)
+	if spanContext.IsValid() {
+		entry.Data["trace_id"] = spanContext.TraceID().String()
+		entry.Data["span_id"] = spanContext.SpanID().String()
+	}
+	return nil
+}
+
+// routeTemplate returns the matched mux route template, or "unmatched"
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+// statusRecorder captures the response status code for the span
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,200 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"kubernetes-api/internal/attributes"
+	"kubernetes-api/internal/labels"
+)
+
+// FieldError describes one field that failed a rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of every failing field of a request
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// rule checks a string value against an optional parameter, returning a
+// message when the value is invalid
+type rule func(value, param string) (string, bool)
+
+// rules are the checks available in `validate` struct tags, e.g.
+// `validate:"required,min=3,max=32,username"`
+var rules = map[string]rule{
+	"required": func(value, _ string) (string, bool) {
+		return "is required", strings.TrimSpace(value) != ""
+	},
+	"min": func(value, param string) (string, bool) {
+		n, _ := strconv.Atoi(param)
+		return fmt.Sprintf("must be at least %d characters", n), utf8.RuneCountInString(value) >= n
+	},
+	"max": func(value, param string) (string, bool) {
+		n, _ := strconv.Atoi(param)
+		return fmt.Sprintf("must be at most %d characters", n), utf8.RuneCountInString(value) <= n
+	},
+	"maxbytes": func(value, param string) (string, bool) {
+		n, _ := strconv.Atoi(param)
+		return fmt.Sprintf("must be at most %d bytes", n), len(value) <= n
+	},
+	"email": func(value, _ string) (string, bool) {
+		addr, err := mail.ParseAddress(value)
+		return "must be a valid email address", err == nil && addr.Address == value && addr.Name == ""
+	},
+	"username": func(value, _ string) (string, bool) {
+		for _, c := range value {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+				return "may only contain letters, digits, '.', '_' and '-'", false
+			}
+		}
+		return "", true
+	},
+	"oneof": func(value, param string) (string, bool) {
+		allowed := strings.Fields(param)
+		return "must be one of " + strings.Join(allowed, ", "), slices.Contains(allowed, value)
+	},
+	"printable": func(value, _ string) (string, bool) {
+		for _, c := range value {
+			if c < 0x20 || c == 0x7f {
+				return "must not contain control characters", false
+			}
+		}
+		return "", true
+	},
+}
+
+// Struct validates the string fields of the struct v points to against their
+// `validate` tags and returns every failure, keyed by the JSON field name.
+// Only the first failing rule of each field is reported. The rules of string
+// slices apply to each element, reported as field[i]. Label and attribute
+// maps are checked with the `validate:"labels"` and `validate:"attributes"`
+// tags.
+func Struct(v interface{}) Errors {
+	val := reflect.Indirect(reflect.ValueOf(v))
+	typ := val.Type()
+
+	var errs Errors
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("validate")
+		switch tag {
+		case "labels":
+			errs = append(errs, Labels(jsonName(field), val.Field(i).Interface().(map[string]string))...)
+			continue
+		case "attributes":
+			errs = append(errs, Attributes(jsonName(field), val.Field(i).Interface().(map[string]interface{}))...)
+			continue
+		}
+		if tag == "" {
+			continue
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.String:
+			errs = append(errs, checkValue(typ, field, jsonName(field), val.Field(i).String(), tag)...)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			values := val.Field(i)
+			for j := 0; j < values.Len(); j++ {
+				errs = append(errs, checkValue(typ, field, fmt.Sprintf("%s[%d]", jsonName(field), j), values.Index(j).String(), tag)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkValue checks one string value of field against the rules in tag,
+// returning the first failure reported under name
+func checkValue(typ reflect.Type, field reflect.StructField, name, value, tag string) Errors {
+	for _, spec := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(spec, "=")
+		check, ok := rules[rule]
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown rule %q on %s.%s", rule, typ.Name(), field.Name))
+		}
+		// Optional fields are only checked when set
+		if rule != "required" && value == "" {
+			continue
+		}
+		if msg, ok := check(value, param); !ok {
+			return Errors{{Field: name, Rule: rule, Message: msg}}
+		}
+	}
+	return nil
+}
+
+// Labels validates the keys and values of a label set, reporting failures
+// under field.key
+func Labels(field string, set map[string]string) Errors {
+	if len(set) > labels.MaxLabels {
+		return Errors{{Field: field, Rule: "labels", Message: fmt.Sprintf("must have at most %d labels", labels.MaxLabels)}}
+	}
+
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs Errors
+	for _, key := range keys {
+		err := labels.CheckKey(key)
+		if err == nil {
+			err = labels.CheckValue(set[key])
+		}
+		if err != nil {
+			errs = append(errs, FieldError{Field: field + "." + key, Rule: "labels", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// Attributes validates the namespaces and the size of an attribute map.
+// Namespace schemas are checked by the API, which loads them.
+func Attributes(field string, attrs map[string]interface{}) Errors {
+	if data, err := json.Marshal(attrs); err != nil || len(data) > attributes.MaxBytes {
+		return Errors{{Field: field, Rule: "attributes", Message: fmt.Sprintf("must encode to at most %d bytes", attributes.MaxBytes)}}
+	}
+
+	namespaces := make([]string, 0, len(attrs))
+	for namespace := range attrs {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var errs Errors
+	for _, namespace := range namespaces {
+		if err := attributes.CheckName(namespace); err != nil {
+			errs = append(errs, FieldError{Field: field + "." + namespace, Rule: "attributes", Message: "namespace " + err.Error()})
+		}
+	}
+	return errs
+}
+
+// jsonName returns the name a struct field is encoded under
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -2,13 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"kubernetes-api/internal/api"
 	"kubernetes-api/internal/auth"
 	"kubernetes-api/internal/database"
+	"kubernetes-api/internal/health"
+	"kubernetes-api/internal/migrations"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/ratelimit"
+	"kubernetes-api/internal/repository"
+	"kubernetes-api/internal/tracing"
 	"kubernetes-api/pkg/utils"
 
 	"github.com/sirupsen/logrus"
@@ -17,12 +26,40 @@ import (
 // Application version
 const appVersion = "1.0.0"
 
+// tracingFlushTimeout is how long buffered spans may take to export at shutdown
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
 	// Setup logging
 	utils.SetupLogger()
+
+	// Run administrative subcommands instead of the server when asked to
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(os.Args[2:])
+		case "set-role":
+			err = runSetRole(os.Args[2:])
+		default:
+			logrus.Fatalf("Unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			logrus.WithError(err).Fatalf("%s command failed", os.Args[1])
+		}
+		return
+	}
+
 	logrus.Info("Starting Kubernetes API service...")
 	logrus.Infof("Version: %s", appVersion)
 
+	// Initialize tracing and tag logs with trace IDs
+	shutdownTracing, err := tracing.Init(context.Background(), "kubernetes-api", appVersion)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize tracing")
+	}
+	logrus.AddHook(tracing.LogHook{})
+
 	// Initialize authentication
 	if err := auth.InitAuth(); err != nil {
 		logrus.WithError(err).Fatal("Failed to initialize authentication")
@@ -34,9 +71,88 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// Register the dependency checks behind the probe endpoints
+	checkTimeout, err := time.ParseDuration(utils.GetEnv("HEALTH_CHECK_TIMEOUT", "2s"))
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid HEALTH_CHECK_TIMEOUT")
+	}
+	checks := health.NewRegistry(checkTimeout)
+	checks.Register("database", database.Ping, health.Readiness)
+	checks.Register("migrations", func(ctx context.Context) error {
+		return migrations.CheckApplied(ctx, database.DB)
+	}, health.Readiness, health.Startup)
+	checks.Register("signing-keys", auth.CheckKeys, health.Readiness, health.Startup)
+
+	// Time between failing readiness and closing the listener, so the pod is
+	// removed from the Service endpoints before connections are refused
+	drainDelay, err := time.ParseDuration(utils.GetEnv("SHUTDOWN_DRAIN_DELAY", "5s"))
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid SHUTDOWN_DRAIN_DELAY")
+	}
+
+	// Rate limits and login lockout, shared across replicas when stored in Postgres
+	rateLimits, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid rate limit configuration")
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	var lockout ratelimit.Lockout = ratelimit.NewMemoryLockout(rateLimits.Lockout)
+	if rateLimits.Store == "postgres" {
+		pgStore := ratelimit.NewPostgresStore(database.DB)
+		pgLockout := ratelimit.NewPostgresLockout(database.DB, rateLimits.Lockout)
+		go purgeRateLimits(pgStore, pgLockout)
+		rateLimitStore, lockout = pgStore, pgLockout
+	}
+
+	// Deleted items are purged from the trash after the retention period
+	items := repository.NewPostgresItemRepository(database.DB)
+	trashRetention, err := time.ParseDuration(utils.GetEnv("ITEM_TRASH_RETENTION", "720h"))
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid ITEM_TRASH_RETENTION")
+	}
+	if trashRetention > 0 {
+		go purgeTrash(items, trashRetention)
+	}
+
+	// Imports are leased by the instance running them. Those left behind by
+	// an earlier run of this instance cannot resume, and those of instances
+	// that stopped renewing their lease are failed as they are found.
+	instanceID := utils.GetEnv("INSTANCE_ID", "")
+	if instanceID == "" {
+		if instanceID, err = os.Hostname(); err != nil {
+			logrus.WithError(err).Fatal("Failed to read hostname for INSTANCE_ID")
+		}
+	}
+	imports := repository.NewPostgresImportJobRepository(database.DB)
+	failAbandonedImports(imports, instanceID)
+	go watchImportLeases(imports)
+
+	maxBatchItems, err := strconv.Atoi(utils.GetEnv("BATCH_MAX_ITEMS", "100"))
+	if err != nil || maxBatchItems < 1 {
+		logrus.WithError(err).Fatal("Invalid BATCH_MAX_ITEMS")
+	}
+
 	// Setup HTTP server
 	port := utils.GetEnv("PORT", "8080")
-	router := api.SetupRouter()
+	router, shutdownImports := api.SetupRouter(api.Dependencies{
+		Items:            items,
+		Users:            repository.NewPostgresUserRepository(database.DB),
+		Sessions:         repository.NewPostgresSessionRepository(database.DB),
+		APIKeys:          repository.NewPostgresAPIKeyRepository(database.DB),
+		AttributeSchemas: repository.NewPostgresAttributeSchemaRepository(database.DB),
+		Imports:          imports,
+		InstanceID:       instanceID,
+
+		RateLimiter: ratelimit.NewLimiter(rateLimitStore, rateLimits.TrustedProxyHops),
+		RateLimits:  rateLimits,
+		Lockout:     lockout,
+
+		Health:  checks,
+		Version: appVersion,
+
+		RequireIfMatch: utils.GetEnv("REQUIRE_IF_MATCH", "false") == "true",
+		MaxBatchItems:  maxBatchItems,
+	})
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", port),
@@ -56,11 +172,83 @@ func main() {
 
 	// Handle graceful shutdown
 	utils.GracefulShutdown(func(ctx context.Context) error {
+		checks.SetShuttingDown()
+		logrus.Infof("Readiness failing, draining for %s", drainDelay)
+		time.Sleep(drainDelay)
+
 		logrus.Info("Shutting down HTTP server...")
 		// Shutdown the server
-		if err := server.Shutdown(ctx); err != nil {
-			return err
+		shutdownErr := server.Shutdown(ctx)
+
+		// No imports can start once the server is down. Running ones are
+		// marked as interrupted while the database is still open; any left
+		// unfinished are failed once their lease expires.
+		logrus.Info("Stopping running imports...")
+		if err := shutdownImports(ctx); err != nil {
+			logrus.WithError(err).Warn("Imports did not stop in time")
 		}
-		return nil
+
+		// Flush buffered spans, even when the steps above ran out of time
+		tracingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tracingFlushTimeout)
+		defer cancel()
+		return errors.Join(shutdownErr, shutdownTracing(tracingCtx))
 	})
 }
+
+// purgeTrash periodically deletes items that have been in the trash for longer than retention
+func purgeTrash(items repository.ItemRepository, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		purged, err := items.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to purge trashed items")
+		} else if purged > 0 {
+			logrus.Infof("Purged %d items from the trash", purged)
+		}
+		cancel()
+	}
+}
+
+// purgeRateLimits periodically deletes idle rate limit buckets and expired login failures
+func purgeRateLimits(store *ratelimit.PostgresStore, lockout *ratelimit.PostgresLockout) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := store.Purge(ctx, time.Hour); err != nil {
+			logrus.WithError(err).Warn("Failed to purge rate limit buckets")
+		}
+		if err := lockout.Purge(ctx); err != nil {
+			logrus.WithError(err).Warn("Failed to purge login failures")
+		}
+		cancel()
+	}
+}
+
+// watchImportLeases periodically fails the import jobs whose lease expired
+func watchImportLeases(imports repository.ImportJobRepository) {
+	ticker := time.NewTicker(repository.ImportJobLease)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		failAbandonedImports(imports, "")
+	}
+}
+
+// failAbandonedImports fails the import jobs whose lease expired, and those of
+// instanceID when it is set
+func failAbandonedImports(imports repository.ImportJobRepository, instanceID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	failed, err := imports.FailAbandoned(ctx, instanceID, models.ImportInterrupted)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to mark abandoned imports as failed")
+	} else if failed > 0 {
+		logrus.Infof("Marked %d abandoned imports as failed", failed)
+	}
+}
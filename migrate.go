@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"kubernetes-api/internal/database"
+	"kubernetes-api/internal/migrations"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runMigrate implements the `migrate up|down [steps]|status` subcommand
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s migrate up|down [steps]|status", os.Args[0])
+	}
+
+	if err := database.Connect(); err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		if err := migrations.Up(ctx, database.DB); err != nil {
+			return err
+		}
+		logrus.Info("Migrations applied")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		if err := migrations.Down(ctx, database.DB, steps); err != nil {
+			return err
+		}
+		logrus.Infof("Rolled back %d migration(s)", steps)
+	case "status":
+		statuses, err := migrations.CurrentStatus(ctx, database.DB)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
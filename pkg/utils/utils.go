@@ -73,6 +73,11 @@ func GetEnvWithPrefix(prefix, key, fallback string) string {
 type contextKey string
 
 const (
-	UserIDKey   contextKey = "userID"
-	UsernameKey contextKey = "username"
+	UserIDKey    contextKey = "userID"
+	UsernameKey  contextKey = "username"
+	RoleKey      contextKey = "role"
+	SessionIDKey contextKey = "sessionID"
+	ScopesKey    contextKey = "scopes"
+	APIKeyIDKey  contextKey = "apiKeyID"
+	RequestIDKey contextKey = "requestID"
 )
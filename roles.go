@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"kubernetes-api/internal/database"
+	"kubernetes-api/internal/models"
+	"kubernetes-api/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runSetRole implements the `set-role <username> <role>` subcommand, used to
+// bootstrap the first admin
+func runSetRole(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s set-role <username> admin|editor|viewer", os.Args[0])
+	}
+
+	username, role := args[0], models.Role(args[1])
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	if err := database.Connect(); err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	users := repository.NewPostgresUserRepository(database.DB)
+	user, err := users.GetByUsername(ctx, username)
+	if err != nil {
+		return fmt.Errorf("failed to find user %q: %w", username, err)
+	}
+
+	if err := users.UpdateRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	logrus.Infof("Set role of %s to %s", username, role)
+	return nil
+}